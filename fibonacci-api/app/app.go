@@ -14,25 +14,73 @@ import (
 	"time"
 )
 
+// jobTimeout is how long a single calculation may run before it is stopped and marked as timed out.
+const jobTimeout = 10 * time.Minute
+
+// jobDeadline is how long a graceful shutdown waits for running jobs before cancelling them.
+const jobDeadline = 20 * time.Second
+
 //Start the server on the given port.
 func Start(port string) {
 	logger.InfoLogger.Println(fmt.Sprintf("Starting server on localhost:%s ...", port))
 
+	cfg := loadConfig()
+
 	//wiring
 	fibRepository := domain.NewFibRepository()
+	fibRepository.JobTimeout = jobTimeout
+	fibRepository.Scheduler = domain.NewScheduler(cfg.Workers, cfg.QueueDepth, cfg.RetryAfter)
+	fibRepository.Scheduler.SetPriorityPolicy(cfg.Priority)
+	fibRepository.Scheduler.SetClientWeights(cfg.ClientWeights)
+	fibRepository.Registry.Register(domain.NewRaceAlgorithm(fibRepository.Registry, fibRepository.Planner, fibRepository.Scheduler))
+	fibRepository.Cache = domain.NewResultCache(int64(cfg.CacheBytes))
+	fibRepository.Checkpoints = domain.NewCheckpointStore(uint64(cfg.CheckpointSpacing), int64(cfg.CheckpointBytes))
+	fibRepository.Registry.RegisterBuiltIn(domain.NewCheckpointAlgorithm(fibRepository.Checkpoints))
+	for name, maxInput := range cfg.MaxInputs {
+		if appError := fibRepository.Registry.SetMaxInput(name, maxInput); appError != nil {
+			logger.WarningLogger.Println("Ignoring FIB_MAX_INPUTS entry:", appError.Message)
+		}
+	}
+	if cfg.ResultsDir != "" {
+		results, err := domain.NewResultStore(cfg.ResultsDir, int64(cfg.ResultThresholdBytes))
+		if err != nil {
+			logger.WarningLogger.Println("Keeping all results in memory, cannot use results directory:", err)
+		} else {
+			fibRepository.Results = results
+		}
+	}
 	Handler := fibHandler{
-		fibService: service.NewFibonacciService(fibRepository),
+		fibService:        service.NewFibonacciService(fibRepository),
+		numberFormat:      cfg.NumberFormat,
+		maxIndex:          cfg.MaxIndex,
+		maxRecurrenceBits: cfg.MaxRecurrenceBits,
+		maxRange:          cfg.MaxRange,
+		maxRangeBytes:     cfg.MaxRangeBytes,
 	}
 
 	//Create channel to monitor whether a shutdown has been initiated
 	quit := make(chan bool)
 
+	//Create the context every calculation runs under so running jobs can be cancelled on shutdown
+	jobContext, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	//Fill the checkpoint table in the background so the server can take requests right away
+	if cfg.CheckpointWarmUp > 0 {
+		go func() {
+			if err := fibRepository.Checkpoints.WarmUp(jobContext, uint64(cfg.CheckpointWarmUp)); err == nil {
+				logger.InfoLogger.Println("Checkpoints warmed up to", fibRepository.Checkpoints.Highest())
+			}
+		}()
+	}
+
 	//Create Router object
 	wg := &sync.WaitGroup{}
 	router := &Router{
-		Handler:   &Handler,
-		WaitGroup: wg,
-		quitChan:  &quit,
+		Handler:    &Handler,
+		WaitGroup:  wg,
+		JobContext: jobContext,
+		quitChan:   &quit,
 	}
 
 	//Construct listen address and then server
@@ -50,11 +98,16 @@ func Start(port string) {
 	go func() {
 		<-quit
 		logger.InfoLogger.Println("Server is shutting down...")
+		fibRepository.Scheduler.Close()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 		log.Println("waiting for running jobs to finish")
-		wg.Wait()
+		if !waitTimeout(wg, jobDeadline) {
+			logger.WarningLogger.Println("Running jobs did not finish in time, cancelling them...")
+			cancelJobs()
+			wg.Wait()
+		}
 		server.SetKeepAlivesEnabled(false)
 		if err := server.Shutdown(ctx); err != nil {
 			logger.ErrorLogger.Fatal("Could not gracefully shutdown the server: ", err)
@@ -71,3 +124,18 @@ func Start(port string) {
 	//<-done
 	logger.InfoLogger.Println("Server stopped")
 }
+
+// waitTimeout waits for the wait group to finish and reports whether it did so before the timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fibonacci-api/domain"
+	"fibonacci-api/dto"
+	"fibonacci-api/logger"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config holds the server settings that can be changed through environment variables.
+type config struct {
+	// Workers is the number of calculations that run at the same time. FIB_WORKERS
+	Workers int
+	// QueueDepth is the number of calculations that wait for a free worker before clients get 429. FIB_QUEUE_DEPTH
+	QueueDepth int
+	// RetryAfter is the delay suggested to clients whose jobs were rejected. FIB_RETRY_AFTER_SECONDS
+	RetryAfter time.Duration
+	// Priority decides how queued jobs of different priority classes are served. FIB_PRIORITY_POLICY (weighted or
+	// strict), FIB_PRIORITY_WEIGHTS (e.g. interactive=6,normal=3,batch=1) and FIB_PRIORITY_MAX_WAIT_SECONDS
+	Priority domain.PriorityPolicy
+	// ClientWeights is the fair share weight of each client identity, written key:<api key> or addr:<ip>.
+	// FIB_CLIENT_WEIGHTS (e.g. key:reporting=4,addr:10.0.0.7=2)
+	ClientWeights map[string]int
+	// CacheBytes is the memory budget of the result cache. 0 disables it. FIB_CACHE_BYTES
+	CacheBytes int
+	// CheckpointSpacing is the distance between the checkpoints used by the checkpoint algorithm.
+	// FIB_CHECKPOINT_SPACING
+	CheckpointSpacing int
+	// CheckpointWarmUp is the largest index up to which checkpoints are computed at startup. 0 disables the warm-up.
+	// FIB_CHECKPOINT_WARMUP
+	CheckpointWarmUp int
+	// CheckpointBytes is the memory budget of the checkpoints. FIB_CHECKPOINT_BYTES
+	CheckpointBytes int
+	// MaxInputs raises or lowers the largest input of individual algorithms. FIB_MAX_INPUTS
+	// (e.g. doubling=10000000,matrix=10000000)
+	MaxInputs map[string]int
+	// MaxIndex is the largest index accepted by /lucas and /recurrence. FIB_MAX_INDEX
+	MaxIndex int
+	// MaxRecurrenceBits is the largest estimated size in bits of the terms computed by /recurrence.
+	// FIB_MAX_RECURRENCE_BITS
+	MaxRecurrenceBits int
+	// MaxRange is the largest number of terms a range job may compute. FIB_MAX_RANGE
+	MaxRange int
+	// MaxRangeBytes is the largest estimated memory the terms of a range job may take. FIB_MAX_RANGE_BYTES
+	MaxRangeBytes int
+	// ResultsDir is the directory large results are written to. Empty keeps every result in memory. FIB_RESULTS_DIR
+	ResultsDir string
+	// ResultThresholdBytes is the size above which results are written to ResultsDir. FIB_RESULT_THRESHOLD_BYTES
+	ResultThresholdBytes int
+	// NumberFormat is how numbers are encoded in responses unless the request asks for another format: json-number,
+	// decimal, hex or base64. FIB_NUMBER_FORMAT
+	NumberFormat string
+}
+
+// loadConfig reads the configuration from the environment, falling back to defaults for unset or invalid values.
+func loadConfig() config {
+	return config{
+		Workers:              envInt("FIB_WORKERS", runtime.NumCPU()),
+		QueueDepth:           envInt("FIB_QUEUE_DEPTH", domain.DefaultQueueDepth),
+		RetryAfter:           time.Duration(envInt("FIB_RETRY_AFTER_SECONDS", int(domain.DefaultRetryAfter.Seconds()))) * time.Second,
+		Priority:             priorityPolicy(),
+		ClientWeights:        envWeights("FIB_CLIENT_WEIGHTS", os.Getenv("FIB_CLIENT_WEIGHTS")),
+		CacheBytes:           envInt("FIB_CACHE_BYTES", domain.DefaultCacheBytes),
+		CheckpointSpacing:    envInt("FIB_CHECKPOINT_SPACING", domain.DefaultCheckpointSpacing),
+		CheckpointWarmUp:     envInt("FIB_CHECKPOINT_WARMUP", 0),
+		CheckpointBytes:      envInt("FIB_CHECKPOINT_BYTES", domain.DefaultCheckpointBytes),
+		MaxInputs:            envWeights("FIB_MAX_INPUTS", os.Getenv("FIB_MAX_INPUTS")),
+		MaxIndex:             envInt("FIB_MAX_INDEX", domain.DefaultMaxInput),
+		MaxRecurrenceBits:    envInt("FIB_MAX_RECURRENCE_BITS", domain.DefaultMaxRecurrenceBits),
+		MaxRange:             envInt("FIB_MAX_RANGE", domain.DefaultMaxRange),
+		MaxRangeBytes:        envInt("FIB_MAX_RANGE_BYTES", domain.DefaultMaxRangeBytes),
+		ResultsDir:           envString("FIB_RESULTS_DIR", "results"),
+		ResultThresholdBytes: envInt("FIB_RESULT_THRESHOLD_BYTES", domain.DefaultResultThresholdBytes),
+		NumberFormat:         numberFormat(),
+	}
+}
+
+// numberFormat reads the default number format from the environment, falling back to json-number.
+func numberFormat() string {
+	format, appError := dto.NewRequest{}.ValidateFormat(envString("FIB_NUMBER_FORMAT", ""), dto.FormatJSONNumber)
+	if appError != nil {
+		logger.WarningLogger.Println("Ignoring invalid value for FIB_NUMBER_FORMAT =", os.Getenv("FIB_NUMBER_FORMAT"))
+		return dto.FormatJSONNumber
+	}
+	return format
+}
+
+// priorityPolicy builds the scheduler's priority policy from the environment on top of the default policy.
+func priorityPolicy() domain.PriorityPolicy {
+	policy := domain.DefaultPriorityPolicy()
+	if mode, present := os.LookupEnv("FIB_PRIORITY_POLICY"); present {
+		switch mode {
+		case "strict":
+			policy.Strict = true
+		case "weighted":
+			policy.Strict = false
+		default:
+			logger.WarningLogger.Println("Ignoring invalid value for FIB_PRIORITY_POLICY =", mode)
+		}
+	}
+	if weights, present := os.LookupEnv("FIB_PRIORITY_WEIGHTS"); present {
+		for name, weight := range envWeights("FIB_PRIORITY_WEIGHTS", weights) {
+			policy.Weights[domain.Priority(name)] = weight
+		}
+	}
+	policy.MaxWait = time.Duration(envInt("FIB_PRIORITY_MAX_WAIT_SECONDS", int(policy.MaxWait.Seconds()))) * time.Second
+	return policy
+}
+
+// envWeights parses a comma separated list of name=value pairs with positive values, skipping invalid entries.
+func envWeights(name string, value string) map[string]int {
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			logger.WarningLogger.Println("Ignoring invalid entry in", name, "=", pair)
+			continue
+		}
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil || weight < 1 {
+			logger.WarningLogger.Println("Ignoring invalid entry in", name, "=", pair)
+			continue
+		}
+		weights[parts[0]] = weight
+	}
+	return weights
+}
+
+// envString returns the value of the environment variable, or fallback if it is unset.
+func envString(name string, fallback string) string {
+	if value, present := os.LookupEnv(name); present {
+		return value
+	}
+	return fallback
+}
+
+// envInt returns the non-negative integer stored in the environment variable, or fallback if it is unset or invalid.
+func envInt(name string, fallback int) int {
+	value, present := os.LookupEnv(name)
+	if !present {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		logger.WarningLogger.Println("Ignoring invalid value for", name, "=", value)
+		return fallback
+	}
+	return parsed
+}
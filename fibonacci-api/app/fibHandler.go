@@ -1,64 +1,511 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
+	"fibonacci-api/domain"
 	"fibonacci-api/dto"
+	"fibonacci-api/errs"
 	"fibonacci-api/service"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
+// digitsChunkSize is the number of digits written per chunk when streaming a whole number.
+const digitsChunkSize = 32 << 10
+
 type fibHandler struct {
 	fibService service.FibService
+	// numberFormat is how numbers are encoded when the request does not choose a format.
+	numberFormat string
+	// maxIndex is the largest index accepted by Lucas sequences and linear recurrences.
+	maxIndex int
+	// maxRecurrenceBits is the largest estimated size of the terms computed by linear recurrences.
+	maxRecurrenceBits int
+	// maxRange is the largest number of terms a range job may compute.
+	maxRange int
+	// maxRangeBytes is the largest estimated memory the terms of a range job may take.
+	maxRangeBytes int
+}
+
+// requestedFormat returns the number format asked for by the 'format' query parameter, or else by a format parameter
+// of the Accept header such as "application/json; format=decimal".
+func requestedFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if _, params, err := mime.ParseMediaType(accepted); err == nil && params["format"] != "" {
+			return params["format"]
+		}
+	}
+	return ""
+}
+
+// clientIdentity identifies who sent the request for fair-share scheduling: the API key from the X-API-Key header if
+// present, otherwise the remote address without its port.
+func clientIdentity(r *http.Request) string {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		return "key:" + apiKey
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
 }
 
-// NewSequence takes in the ResponseWriter the Request, the start time, and a pointer to the wait group. Validates the
-// algorithm string and then sends it on to the fibonacci service to process.
-func (fh fibHandler) NewSequence(w http.ResponseWriter, r *http.Request, wg *sync.WaitGroup, algo string) {
+// NewSequence takes in the ResponseWriter the Request, the context the calculation runs under, and a pointer to the
+// wait group. Validates the algorithm string and then sends it on to the fibonacci service to process.
+func (fh fibHandler) NewSequence(w http.ResponseWriter, r *http.Request, ctx context.Context, wg *sync.WaitGroup, algo string) {
 	var request = dto.NewRequest{}
 
 	//Build the request object
 	err := r.ParseForm()
 	if err != nil {
-		writeResponse(w, http.StatusBadRequest, err)
+		writeAppError(w, errs.NewBadRequestError("Could not parse the request: "+err.Error()))
+		return
 	}
 	//Get a validate algo
 	request.Algorithm = algo
-	appError := request.ValidateAlgo()
+	algorithm, appError := request.ValidateAlgo(fh.fibService.Algorithms())
 	if appError != nil {
-		writeResponse(w, http.StatusBadRequest, appError.AsMessage())
+		writeAppError(w, appError)
 		return
 	}
-	//Get a validate inputNum
+	//Get a validate inputNum, or the index n itself
 	inputNum := r.Form.Get("input")
-	num, appError := request.ValidateInputNum(inputNum)
+	var num int
+	if index := r.Form.Get("n"); index != "" {
+		if inputNum != "" {
+			writeAppError(w, errs.NewValidationError("Please provide either input or n, not both"))
+			return
+		}
+		num, appError = request.ValidateFibIndex(index, algorithm.MaxInput)
+	} else {
+		num, appError = request.ValidateInputNum(inputNum, algorithm.MaxInput)
+	}
 	if appError != nil {
-		writeResponse(w, http.StatusBadRequest, appError.AsMessage())
+		writeAppError(w, appError)
 		return
 	}
 	request.Input = num
+	//Get a validate multiply mode for the matrix algorithm
+	if multiply := r.Form.Get("multiply"); multiply != "" {
+		mode, appError := request.ValidateMultiply(multiply)
+		if appError != nil {
+			writeAppError(w, appError)
+			return
+		}
+		request.Multiply = mode
+	}
+	//Get a validate priority
+	priority, appError := request.ValidatePriority(r.Form.Get("priority"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Priority = priority
+	//Get a validate verification flag
+	verify, appError := request.ValidateVerify(r.Form.Get("verify"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Verify = verify
+	request.Client = clientIdentity(r)
 	//Process Input
-	response, appError := fh.fibService.NewSequence(request, wg)
+	response, appError := fh.fibService.NewSequence(ctx, request, wg)
 	if appError != nil {
-		writeResponse(w, appError.Code, appError.AsMessage())
+		writeAppError(w, appError)
 		return
 	}
 	writeResponse(w, http.StatusOK, response.Id)
 }
 
+// NewRange takes in the ResponseWriter the Request, the context the calculation runs under, a pointer to the wait
+// group and the algorithm. Validates the algorithm and the first and last index, then sends them on to the fibonacci
+// service to compute every term of the range in one job.
+func (fh fibHandler) NewRange(w http.ResponseWriter, r *http.Request, ctx context.Context, wg *sync.WaitGroup, algo string) {
+	var request = dto.NewRequest{}
+
+	//Build the request object
+	err := r.ParseForm()
+	if err != nil {
+		writeAppError(w, errs.NewBadRequestError("Could not parse the request: "+err.Error()))
+		return
+	}
+	//Get a validate algo
+	request.Algorithm = algo
+	algorithm, appError := request.ValidateAlgo(fh.fibService.Algorithms())
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	//Get a validate range
+	from, to, appError := request.ValidateRange(r.Form.Get("from"), r.Form.Get("to"), algorithm.MaxInput, fh.maxRange)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	if appError := request.ValidateRangeSize(from, to, fh.maxRangeBytes); appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.From = from
+	request.To = to
+	//Get a validate multiply mode for the matrix algorithm
+	if multiply := r.Form.Get("multiply"); multiply != "" {
+		mode, appError := request.ValidateMultiply(multiply)
+		if appError != nil {
+			writeAppError(w, appError)
+			return
+		}
+		request.Multiply = mode
+	}
+	//Get a validate priority
+	priority, appError := request.ValidatePriority(r.Form.Get("priority"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Priority = priority
+	request.Client = clientIdentity(r)
+	//Process Input
+	response, appError := fh.fibService.NewRange(ctx, request, wg)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	writeResponse(w, http.StatusOK, response.Id)
+}
+
+// Lucas takes in the ResponseWriter, the Request, the context the calculation runs under, and a pointer to the wait
+// group. Validates P, Q and n and then sends them on to the fibonacci service to compute U_n(P, Q) and V_n(P, Q).
+func (fh fibHandler) Lucas(w http.ResponseWriter, r *http.Request, ctx context.Context, wg *sync.WaitGroup) {
+	var request = dto.NewRequest{}
+
+	//Build the request object
+	err := r.ParseForm()
+	if err != nil {
+		writeAppError(w, errs.NewBadRequestError("Could not parse the request: "+err.Error()))
+		return
+	}
+	//Get a validate P and Q
+	p, appError := request.ValidateLucasParameter(r.Form.Get("p"), "P")
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.P = p
+	q, appError := request.ValidateLucasParameter(r.Form.Get("q"), "Q")
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Q = q
+	//Get a validate index
+	n, appError := request.ValidateIndex(r.Form.Get("n"), fh.maxIndex)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Input = n
+	//Get a validate priority
+	priority, appError := request.ValidatePriority(r.Form.Get("priority"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Priority = priority
+	request.Client = clientIdentity(r)
+	//Process Input
+	response, appError := fh.fibService.NewLucas(ctx, request, wg)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	writeResponse(w, http.StatusOK, response.Id)
+}
+
+// Recurrence takes in the ResponseWriter, the Request, the context the calculation runs under, and a pointer to the
+// wait group. Validates the coefficients, seeds and n and then sends them on to the fibonacci service to compute the
+// nth term of the linear recurrence.
+func (fh fibHandler) Recurrence(w http.ResponseWriter, r *http.Request, ctx context.Context, wg *sync.WaitGroup) {
+	var request = dto.NewRequest{}
+
+	//Build the request object
+	err := r.ParseForm()
+	if err != nil {
+		writeAppError(w, errs.NewBadRequestError("Could not parse the request: "+err.Error()))
+		return
+	}
+	//Get a validate recurrence
+	coefficients, seeds, appError := request.ValidateRecurrence(r.Form.Get("coefficients"), r.Form.Get("seeds"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Coefficients = coefficients
+	request.Seeds = seeds
+	//Get a validate index
+	n, appError := request.ValidateIndex(r.Form.Get("n"), fh.maxIndex)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Input = n
+	if appError := request.ValidateRecurrenceSize(n, coefficients, seeds, fh.maxRecurrenceBits); appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	//Get a validate priority
+	priority, appError := request.ValidatePriority(r.Form.Get("priority"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Priority = priority
+	request.Client = clientIdentity(r)
+	//Process Input
+	response, appError := fh.fibService.NewRecurrence(ctx, request, wg)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	writeResponse(w, http.StatusOK, response.Id)
+}
+
+// FibMod takes in the ResponseWriter and the Request. Validates n and m and writes F(n) mod m, which is computed
+// without queueing a job.
+func (fh fibHandler) FibMod(w http.ResponseWriter, r *http.Request) {
+	var request = dto.NewRequest{}
+	n, appError := request.ValidateModIndex(r.URL.Query().Get("n"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.ModIndex = n
+	m, appError := request.ValidateModulus(r.URL.Query().Get("m"), domain.MaxModulus)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Modulus = m
+	writeResponse(w, http.StatusOK, fh.fibService.FibMod(request))
+}
+
+// Pisano takes in the ResponseWriter and the Request. Validates m and writes the Pisano period of m.
+func (fh fibHandler) Pisano(w http.ResponseWriter, r *http.Request) {
+	var request = dto.NewRequest{}
+	m, appError := request.ValidateModulus(r.URL.Query().Get("m"), domain.MaxModulus)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Modulus = m
+	writeResponse(w, http.StatusOK, fh.fibService.Pisano(request))
+}
+
 // FindBy takes in the ResponseWriter and the fib identifier. Validates the identifier then passes it on to the
 // fibService to process
-func (fh fibHandler) FindBy(w http.ResponseWriter, id string) {
+func (fh fibHandler) FindBy(w http.ResponseWriter, r *http.Request, id string) {
 	var request = dto.NewRequest{}
 	fibId, appError := request.ValidateId(id)
 	if appError != nil {
-		writeResponse(w, appError.Code, appError.AsMessage())
+		writeAppError(w, appError)
 		return
 	}
 	request.Id = fibId
+	//Get a validate flag for leaving out the number
+	includeFib, appError := request.ValidateIncludeFib(r.URL.Query().Get("fib"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.OmitFib = !includeFib
+	//Get a validate page of range terms
+	request.Offset, request.Limit, appError = request.ValidatePage(r.URL.Query().Get("offset"), r.URL.Query().Get("limit"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	//Get a validate number format
+	request.Format, appError = request.ValidateFormat(requestedFormat(r), fh.numberFormat)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
 	sequence, appError := fh.fibService.FindById(request)
 	if appError != nil {
-		writeResponse(w, appError.Code, appError.AsMessage())
+		writeAppError(w, appError)
+	} else {
+		writeResponse(w, http.StatusOK, sequence)
+	}
+}
+
+// Digits takes in the ResponseWriter, the Request and the fib identifier. Validates the identifier and base, then
+// streams the digits of the number as plain text, reading them from the results directory for results stored there.
+// Range requests get the requested slices of the digits; full downloads are sent in chunks with chunked transfer
+// encoding.
+func (fh fibHandler) Digits(w http.ResponseWriter, r *http.Request, id string) {
+	var request = dto.NewRequest{}
+	fibId, appError := request.ValidateId(id)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Id = fibId
+	base, appError := request.ValidateBase(r.URL.Query().Get("base"))
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Base = base
+	digits, appError := fh.fibService.Digits(request)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	defer digits.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if r.Header.Get("Range") != "" {
+		http.ServeContent(w, r, "", time.Time{}, digits)
+		return
+	}
+	streamDigits(w, digits)
+}
+
+// RangeTerms takes in the ResponseWriter, the Request, the fib identifier and the download format, ndjson or csv.
+// Validates the identifier and streams every term of the range job, one per line. NDJSON lines are objects encoding
+// the number in the requested number format; CSV rows hold the index and the decimal number.
+func (fh fibHandler) RangeTerms(w http.ResponseWriter, r *http.Request, id string, download string) {
+	var request = dto.NewRequest{}
+	fibId, appError := request.ValidateId(id)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Id = fibId
+	request.Format, appError = request.ValidateFormat(requestedFormat(r), fh.numberFormat)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	if download == "csv" {
+		request.Format = dto.FormatDecimal
+	}
+	terms, appError := fh.fibService.RangeTerms(request)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	if download == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+	streamTerms(w, terms, download)
+}
+
+// Cancel takes in the ResponseWriter and the fib identifier. Validates the identifier then asks the fibService to stop
+// the calculation.
+func (fh fibHandler) Cancel(w http.ResponseWriter, r *http.Request, id string) {
+	var request = dto.NewRequest{}
+	fibId, appError := request.ValidateId(id)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	request.Id = fibId
+	request.Format, appError = request.ValidateFormat(requestedFormat(r), fh.numberFormat)
+	if appError != nil {
+		writeAppError(w, appError)
+		return
+	}
+	sequence, appError := fh.fibService.Cancel(request)
+	if appError != nil {
+		writeAppError(w, appError)
 	} else {
 		writeResponse(w, http.StatusOK, sequence)
 	}
 }
+
+// Algorithms writes the list of registered algorithms so clients can discover what /fib/{algorithm} accepts.
+func (fh fibHandler) Algorithms(w http.ResponseWriter) {
+	writeResponse(w, http.StatusOK, fh.fibService.Algorithms())
+}
+
+// CacheStats writes the hit and miss counters of the result cache.
+func (fh fibHandler) CacheStats(w http.ResponseWriter) {
+	writeResponse(w, http.StatusOK, fh.fibService.CacheStats())
+}
+
+// writeAppError writes the error with its status code, telling clients when to retry if the error carries a delay.
+func writeAppError(w http.ResponseWriter, appError *errs.AppError) {
+	if appError.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(appError.RetryAfter))
+	}
+	writeResponse(w, appError.Code, appError.AsMessage())
+}
+
+// streamDigits copies the digits in chunks, flushing after each one. Without a Content-Length the server sends them
+// with chunked transfer encoding.
+func streamDigits(w http.ResponseWriter, digits io.Reader) {
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.WriteHeader(http.StatusOK)
+	flusher, canFlush := w.(http.Flusher)
+	chunk := make([]byte, digitsChunkSize)
+	for {
+		read, err := io.ReadFull(digits, chunk)
+		if read > 0 {
+			if _, writeErr := w.Write(chunk[:read]); writeErr != nil {
+				return
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// streamTerms writes one line per term, as CSV rows after an "index,fib" header or as NDJSON objects, flushing after
+// every chunk of lines. Without a Content-Length the server sends them with chunked transfer encoding.
+func streamTerms(w http.ResponseWriter, terms []dto.RangeTerm, download string) {
+	flusher, canFlush := w.(http.Flusher)
+	var chunk []byte
+	if download == "csv" {
+		chunk = append(chunk, "index,fib\n"...)
+	}
+	for i, term := range terms {
+		if download == "csv" {
+			chunk = append(chunk, strconv.FormatInt(term.Index, 10)+","+term.Fib.Value.String()+"\n"...)
+		} else {
+			encoded, err := json.Marshal(term)
+			if err != nil {
+				return
+			}
+			chunk = append(append(chunk, encoded...), '\n')
+		}
+		if len(chunk) < digitsChunkSize && i < len(terms)-1 {
+			continue
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+		chunk = chunk[:0]
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fibonacci-api/errs"
 	"fibonacci-api/logger"
@@ -11,9 +12,10 @@ import (
 )
 
 type Router struct {
-	Handler   *fibHandler
-	WaitGroup *sync.WaitGroup
-	quitChan  *chan bool
+	Handler    *fibHandler
+	WaitGroup  *sync.WaitGroup
+	JobContext context.Context
+	quitChan   *chan bool
 }
 
 //define routes
@@ -34,12 +36,72 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		//check for algo
 		var algorithm string
 		algorithm, r.URL.Path = shiftPath(r.URL.Path)
-		router.Handler.NewSequence(w, r, router.WaitGroup, algorithm)
+		if algorithm == "mod" && r.Method == http.MethodGet {
+			//F(n) mod m is answered right away
+			router.Handler.FibMod(w, r)
+			return
+		}
+		//check whether a whole range is requested
+		var part string
+		part, r.URL.Path = shiftPath(r.URL.Path)
+		switch part {
+		case "":
+			router.Handler.NewSequence(w, r, router.JobContext, router.WaitGroup, algorithm)
+		case "range":
+			router.Handler.NewRange(w, r, router.JobContext, router.WaitGroup, algorithm)
+		default:
+			invalidEndpointError(w)
+		}
+	case "lucas":
+		//Lucas sequences are only created with POST
+		if r.Method != http.MethodPost {
+			invalidEndpointError(w)
+			return
+		}
+		router.Handler.Lucas(w, r, router.JobContext, router.WaitGroup)
+	case "recurrence":
+		//Linear recurrences are only created with POST
+		if r.Method != http.MethodPost {
+			invalidEndpointError(w)
+			return
+		}
+		router.Handler.Recurrence(w, r, router.JobContext, router.WaitGroup)
+	case "pisano":
+		//Pisano period of m
+		router.Handler.Pisano(w, r)
 	case "find":
-		//check for id
-		var id string
+		//check for id and whether the digits are requested
+		var id, part string
 		id, r.URL.Path = shiftPath(r.URL.Path)
-		router.Handler.FindBy(w, id)
+		part, r.URL.Path = shiftPath(r.URL.Path)
+		switch part {
+		case "":
+			router.Handler.FindBy(w, r, id)
+		case "digits":
+			router.Handler.Digits(w, r, id)
+		case "range.ndjson":
+			router.Handler.RangeTerms(w, r, id, "ndjson")
+		case "range.csv":
+			router.Handler.RangeTerms(w, r, id, "csv")
+		default:
+			invalidEndpointError(w)
+		}
+	case "jobs":
+		//check for id and action
+		var id, action string
+		id, r.URL.Path = shiftPath(r.URL.Path)
+		action, r.URL.Path = shiftPath(r.URL.Path)
+		if action != "cancel" || r.Method != http.MethodPost {
+			invalidEndpointError(w)
+			return
+		}
+		router.Handler.Cancel(w, r, id)
+	case "algorithms":
+		//List available algorithms
+		router.Handler.Algorithms(w)
+	case "cache":
+		//Report result cache usage
+		router.Handler.CacheStats(w)
 	case "shutdown":
 		//Shutdown gracefully
 		shutdown(*router.quitChan)
@@ -51,7 +113,7 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // shiftPath splits the given path into the first segment (head) and  the rest (tail).
 // For example, "/foo/bar/baz" gives "foo", "/bar/baz".
-//source: https://blog.merovius.de/2017/06/18/how-not-to-use-an-http-router.html
+// source: https://blog.merovius.de/2017/06/18/how-not-to-use-an-http-router.html
 func shiftPath(p string) (head, tail string) {
 	p = path.Clean("/" + p)
 	i := strings.Index(p[1:], "/") + 1
@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/dto"
+	"fibonacci-api/errs"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxInput is the largest index, in absolute value, accepted by the built-in algorithms.
+const DefaultMaxInput = 99999
+
+//Algorithm defines a way of calculating the fibonacci number for a Sequence.
+type Algorithm interface {
+	// Name is the identifier clients use to select the algorithm, e.g. /fib/{name}.
+	Name() string
+	// Description is a short human readable summary shown by the discovery endpoint.
+	Description() string
+	// MaxInput is the largest index the algorithm supports. Negative indices down to -MaxInput are supported as well.
+	MaxInput() int
+	// Compute calculates F(k) for the given sequence, where k is the absolute value of its index; the repository
+	// applies the sign of negative indices. Implementations should check ctx at regular intervals and return once it
+	// is cancelled.
+	Compute(context.Context, Sequence) (*big.Int, *errs.AppError)
+}
+
+// ComputeFunc calculates the fibonacci number for the given sequence.
+type ComputeFunc func(context.Context, Sequence) (*big.Int, *errs.AppError)
+
+type funcAlgorithm struct {
+	name        string
+	description string
+	maxInput    int
+	compute     ComputeFunc
+}
+
+func (a funcAlgorithm) Name() string        { return a.name }
+func (a funcAlgorithm) Description() string { return a.description }
+func (a funcAlgorithm) MaxInput() int       { return a.maxInput }
+
+func (a funcAlgorithm) Compute(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	return a.compute(ctx, sequence)
+}
+
+// NewAlgorithm wraps a compute function into an Algorithm so it can be registered.
+func NewAlgorithm(name string, description string, maxInput int, compute ComputeFunc) Algorithm {
+	return funcAlgorithm{
+		name:        name,
+		description: description,
+		maxInput:    maxInput,
+		compute:     compute,
+	}
+}
+
+// DefaultAlgorithms returns the algorithms that ship with the server.
+func DefaultAlgorithms() []Algorithm {
+	return []Algorithm{
+		NewAlgorithm("math", "Binet's formula using the golden ratio at a precision that grows with n.", DefaultMaxInput, MathFib),
+		NewAlgorithm("recursive", "Top-down recursion with memoization, run on an explicit stack.", DefaultMaxInput, RecurseFib),
+		NewAlgorithm("iterate", "Bottom-up dynamic programming, one addition per term.", DefaultMaxInput, IterateFib),
+		NewAlgorithm("doubling", "Fast doubling identities, O(log n) big multiplications.", DefaultMaxInput, DoublingFib),
+		NewAlgorithm("matrix", "Q-matrix exponentiation by repeated squaring. Form field 'multiply' selects naive or parallel products.", DefaultMaxInput, MatrixFib),
+	}
+}
+
+//AlgorithmRegistry keeps track of the algorithms that can be used to calculate a Sequence. It remembers which of them
+//ship with the server, since only their results are trusted without verification.
+type AlgorithmRegistry struct {
+	mu         sync.RWMutex
+	algorithms map[string]Algorithm
+	builtIn    map[string]bool
+}
+
+// NewAlgorithmRegistry creates a registry holding the given algorithms.
+func NewAlgorithmRegistry(algorithms ...Algorithm) *AlgorithmRegistry {
+	registry := &AlgorithmRegistry{algorithms: make(map[string]Algorithm), builtIn: make(map[string]bool)}
+	for _, algorithm := range algorithms {
+		registry.Register(algorithm)
+	}
+	return registry
+}
+
+// Register adds the algorithm to the registry, replacing any algorithm already registered under the same name. It is
+// not treated as built-in, even if it replaces one.
+func (registry *AlgorithmRegistry) Register(algorithm Algorithm) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.algorithms[algorithm.Name()] = algorithm
+	delete(registry.builtIn, algorithm.Name())
+}
+
+// RegisterBuiltIn works like Register for an algorithm that ships with the server.
+func (registry *AlgorithmRegistry) RegisterBuiltIn(algorithm Algorithm) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.algorithms[algorithm.Name()] = algorithm
+	registry.builtIn[algorithm.Name()] = true
+}
+
+// BuiltIn reports whether the algorithm registered under the given name ships with the server.
+func (registry *AlgorithmRegistry) BuiltIn(name string) bool {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.builtIn[name]
+}
+
+// Get finds the algorithm registered under the given name.
+func (registry *AlgorithmRegistry) Get(name string) (Algorithm, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	algorithm, ok := registry.algorithms[name]
+	return algorithm, ok
+}
+
+// All returns every registered algorithm sorted by name.
+func (registry *AlgorithmRegistry) All() []Algorithm {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	algorithms := make([]Algorithm, 0, len(registry.algorithms))
+	for _, algorithm := range registry.algorithms {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Slice(algorithms, func(i, j int) bool { return algorithms[i].Name() < algorithms[j].Name() })
+	return algorithms
+}
+
+// SetMaxInput changes the largest input the named algorithm accepts. The limits of auto and race follow from the
+// other algorithms and cannot be set.
+func (registry *AlgorithmRegistry) SetMaxInput(name string, maxInput int) *errs.AppError {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	algorithm, ok := registry.algorithms[name]
+	if !ok || name == AutoAlgorithm || name == RaceAlgorithm {
+		return errs.NewValidationError("Cannot set the largest input of algorithm " + name)
+	}
+	if limited, ok := algorithm.(limitedAlgorithm); ok {
+		algorithm = limited.Algorithm
+	}
+	registry.algorithms[name] = limitedAlgorithm{Algorithm: algorithm, maxInput: maxInput}
+	return nil
+}
+
+// limitedAlgorithm overrides the largest input of the algorithm it wraps.
+type limitedAlgorithm struct {
+	Algorithm
+	maxInput int
+}
+
+func (a limitedAlgorithm) MaxInput() int { return a.maxInput }
+
+// concrete returns the registered algorithms sorted by name, leaving out auto and race, which delegate to the others.
+func (registry *AlgorithmRegistry) concrete() []Algorithm {
+	var algorithms []Algorithm
+	for _, algorithm := range registry.All() {
+		if name := algorithm.Name(); name != AutoAlgorithm && name != RaceAlgorithm {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
+// UnknownAlgorithmError builds the validation error returned when a client asks for an algorithm that isn't registered.
+func (registry *AlgorithmRegistry) UnknownAlgorithmError(name string) *errs.AppError {
+	algorithms := registry.All()
+	names := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		names[i] = algorithm.Name()
+	}
+	return errs.NewValidationError("Please provide valid algorithm: " + strings.Join(names, ", ") + ". Got: " + name)
+}
+
+//ToAlgorithmResponseDto converts an Algorithm into the description returned to the client.
+func ToAlgorithmResponseDto(algorithm Algorithm) dto.AlgorithmResponse {
+	return dto.AlgorithmResponse{
+		Name:        algorithm.Name(),
+		Description: algorithm.Description(),
+		MaxInput:    algorithm.MaxInput(),
+	}
+}
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/errs"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// DefaultCheckpointSpacing is the distance between the indices of the checkpoints kept by NewFibRepository.
+const DefaultCheckpointSpacing = 1024
+
+// DefaultCheckpointBytes is the memory budget of the checkpoints kept by NewFibRepository.
+const DefaultCheckpointBytes = 256 << 20
+
+// checkpoint is the pair (F(index), F(index+1)).
+type checkpoint struct {
+	index uint64
+	fk    *big.Int
+	fk1   *big.Int
+}
+
+// size estimates the memory held by the checkpoint's numbers.
+func (point checkpoint) size() int64 {
+	return entrySize(point.fk) + entrySize(point.fk1)
+}
+
+//CheckpointStore keeps pairs of consecutive fibonacci numbers (F(k), F(k+1)) at every index k that is a multiple of
+//its spacing, so F(n) can be computed from the nearest pair below n instead of from zero. Numbers grow with their
+//index, so once the pairs pass the store's byte budget the ones with the largest indices are dropped. It is safe for
+//concurrent use.
+type CheckpointStore struct {
+	mu        sync.RWMutex
+	spacing   uint64
+	maxBytes  int64
+	usedBytes int64
+	// checkpoints are sorted by index. The pair at index 0 is always present.
+	checkpoints []checkpoint
+}
+
+// NewCheckpointStore creates a store keeping a checkpoint every spacing indices in at most maxBytes of memory. A
+// spacing of 0 uses DefaultCheckpointSpacing and a budget of 0 uses DefaultCheckpointBytes.
+func NewCheckpointStore(spacing uint64, maxBytes int64) *CheckpointStore {
+	if spacing == 0 {
+		spacing = DefaultCheckpointSpacing
+	}
+	if maxBytes == 0 {
+		maxBytes = DefaultCheckpointBytes
+	}
+	zero := checkpoint{index: 0, fk: big.NewInt(0), fk1: big.NewInt(1)}
+	return &CheckpointStore{
+		spacing:     spacing,
+		maxBytes:    maxBytes,
+		usedBytes:   zero.size(),
+		checkpoints: []checkpoint{zero},
+	}
+}
+
+// Spacing returns the distance between the indices of the stored checkpoints.
+func (store *CheckpointStore) Spacing() uint64 {
+	return store.spacing
+}
+
+// Len returns the number of stored checkpoints.
+func (store *CheckpointStore) Len() int {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return len(store.checkpoints)
+}
+
+// Bytes returns the estimated memory held by the stored checkpoints.
+func (store *CheckpointStore) Bytes() int64 {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.usedBytes
+}
+
+// Highest returns the largest index with a stored checkpoint.
+func (store *CheckpointStore) Highest() uint64 {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.checkpoints[len(store.checkpoints)-1].index
+}
+
+// nearest returns the stored checkpoint with the largest index not above n. Its numbers must not be modified.
+func (store *CheckpointStore) nearest(n uint64) checkpoint {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	i := sort.Search(len(store.checkpoints), func(i int) bool { return store.checkpoints[i].index > n })
+	return store.checkpoints[i-1]
+}
+
+// add stores the checkpoint unless its index is not a multiple of the spacing or it is already stored, then drops the
+// checkpoints with the largest indices until the store fits its budget again. It reports whether the checkpoint is
+// still stored afterwards.
+func (store *CheckpointStore) add(point checkpoint) bool {
+	if point.index%store.spacing != 0 {
+		return false
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	i := sort.Search(len(store.checkpoints), func(i int) bool { return store.checkpoints[i].index >= point.index })
+	if i < len(store.checkpoints) && store.checkpoints[i].index == point.index {
+		return true
+	}
+	store.checkpoints = append(store.checkpoints, checkpoint{})
+	copy(store.checkpoints[i+1:], store.checkpoints[i:])
+	store.checkpoints[i] = point
+	store.usedBytes += point.size()
+	// The pair at index 0 is never dropped
+	for store.usedBytes > store.maxBytes && len(store.checkpoints) > 1 {
+		last := len(store.checkpoints) - 1
+		store.usedBytes -= store.checkpoints[last].size()
+		store.checkpoints = store.checkpoints[:last]
+	}
+	return i < len(store.checkpoints)
+}
+
+// WarmUp stores a checkpoint at every multiple of the spacing up to limit. It stops early once ctx is cancelled or the
+// next checkpoint no longer fits the store's budget.
+func (store *CheckpointStore) WarmUp(ctx context.Context, limit uint64) *errs.AppError {
+	point := store.nearest(0)
+	// Every step has the same length, so F(spacing) and F(spacing+1) are computed once
+	fd, fd1 := doublingPair(ctx, store.spacing)
+	for point.index+store.spacing <= limit {
+		if err := cancelled(ctx); err != nil {
+			return err
+		}
+		point = advance(point, store.spacing, fd, fd1)
+		if !store.add(point) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Compute returns F(n), jumping from the nearest stored checkpoint. The checkpoint at the largest multiple of the
+// spacing not above n is stored along the way if it fits the budget, so later requests for nearby numbers start from
+// it.
+func (store *CheckpointStore) Compute(ctx context.Context, n uint64) (*big.Int, *errs.AppError) {
+	point := store.nearest(n)
+	if target := n - n%store.spacing; point.index < target {
+		fd, fd1 := doublingPair(ctx, target-point.index)
+		if err := cancelled(ctx); err != nil {
+			return nil, err
+		}
+		point = advance(point, target-point.index, fd, fd1)
+		store.add(point)
+	}
+	if point.index == n {
+		return new(big.Int).Set(point.fk), nil
+	}
+	fd, fd1 := doublingPair(ctx, n-point.index)
+	if err := cancelled(ctx); err != nil {
+		return nil, err
+	}
+	return advance(point, n-point.index, fd, fd1).fk, nil
+}
+
+// advance moves the checkpoint d indices forward given fd = F(d) and fd1 = F(d+1), using
+// F(m+n) = F(m)F(n+1) + F(m-1)F(n):
+//
+//	F(k+d)   = F(k)F(d+1) + F(k-1)F(d)
+//	F(k+d+1) = F(k+1)F(d+1) + F(k)F(d)
+func advance(point checkpoint, d uint64, fd *big.Int, fd1 *big.Int) checkpoint {
+	fkm1 := new(big.Int).Sub(point.fk1, point.fk)
+	fk := new(big.Int).Mul(point.fk, fd1)
+	fk.Add(fk, fkm1.Mul(fkm1, fd))
+	fk1 := new(big.Int).Mul(point.fk1, fd1)
+	fk1.Add(fk1, new(big.Int).Mul(point.fk, fd))
+	return checkpoint{index: point.index + d, fk: fk, fk1: fk1}
+}
+
+// NewCheckpointAlgorithm creates the "checkpoint" algorithm, which computes numbers from the pairs kept in store.
+func NewCheckpointAlgorithm(store *CheckpointStore) Algorithm {
+	return NewAlgorithm("checkpoint", "Jumps from the nearest stored (F(k), F(k+1)) pair using F(m+n) = F(m)F(n+1) + F(m-1)F(n).", DefaultMaxInput,
+		func(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+			return store.Compute(ctx, sequence.index())
+		})
+}
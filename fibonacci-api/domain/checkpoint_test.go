@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckpointStore_ComputeMatchesDoubling(t *testing.T) {
+	store := NewCheckpointStore(100, 0)
+	for _, n := range []uint64{0, 1, 2, 99, 100, 101, 250, 1000, 1234, 200, 5} {
+		answer, err := store.Compute(context.Background(), n)
+		if err != nil {
+			t.Error("Error was returned while calling Compute: ", err)
+			return
+		}
+		if want := doubling(context.Background(), n); answer.Cmp(want) != 0 {
+			t.Error("Invalid result from checkpoint. n:", n, "Want:", want, "Got:", answer)
+		}
+	}
+}
+
+func TestCheckpointStore_StoresNearestCheckpoint(t *testing.T) {
+	store := NewCheckpointStore(100, 0)
+	store.Compute(context.Background(), 1234)
+	if nearest := store.nearest(1299); nearest.index != 1200 {
+		t.Error("Invalid nearest checkpoint after computing. Want: 1200 Got:", nearest.index)
+	}
+	if store.Len() != 2 {
+		t.Error("Invalid number of checkpoints. Want: 2 Got:", store.Len())
+	}
+}
+
+func TestCheckpointStore_WarmUp(t *testing.T) {
+	store := NewCheckpointStore(64, 0)
+	if err := store.WarmUp(context.Background(), 1000); err != nil {
+		t.Error("Error was returned while calling WarmUp: ", err)
+		return
+	}
+	// Index 0 plus 64, 128, ..., 960
+	if store.Len() != 16 {
+		t.Error("Invalid number of checkpoints after warm up. Want: 16 Got:", store.Len())
+	}
+	for _, point := range store.checkpoints {
+		if want := doubling(context.Background(), point.index); point.fk.Cmp(want) != 0 {
+			t.Error("Invalid checkpoint after warm up. Index:", point.index, "Want:", want, "Got:", point.fk)
+		}
+	}
+}
+
+func TestCheckpointStore_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store := NewCheckpointStore(64, 0)
+	if err := store.WarmUp(ctx, 1000); err == nil {
+		t.Error("Expected an error from a cancelled warm up")
+	}
+	if _, err := store.Compute(ctx, 1000); err == nil {
+		t.Error("Expected an error from a cancelled calculation")
+	}
+}
+
+func TestCheckpointStore_DropsLargestWhenOverBudget(t *testing.T) {
+	// Room for the pair at index 0 and about two more
+	point := NewCheckpointStore(64, 0).nearest(0)
+	store := NewCheckpointStore(64, 3*point.size()+64)
+	if err := store.WarmUp(context.Background(), 100000); err != nil {
+		t.Error("Error was returned while calling WarmUp: ", err)
+		return
+	}
+	if store.Bytes() > 3*point.size()+64 {
+		t.Error("Checkpoints should fit the budget. Budget:", 3*point.size()+64, "Got:", store.Bytes())
+	}
+	if highest := store.Highest(); highest == 0 || highest >= 100000 {
+		t.Error("Warm up should stop once the budget is full. Got highest checkpoint:", highest)
+	}
+	// Computing past the budget still works, it only keeps no new checkpoint
+	answer, err := store.Compute(context.Background(), 5000)
+	if err != nil || answer.Cmp(doubling(context.Background(), 5000)) != 0 {
+		t.Error("Invalid result from checkpoint past the budget. Err:", err)
+	}
+	if store.Bytes() > 3*point.size()+64 {
+		t.Error("Checkpoints should fit the budget after computing. Got:", store.Bytes())
+	}
+}
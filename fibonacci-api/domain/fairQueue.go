@@ -0,0 +1,125 @@
+package domain
+
+// fairQueue shares one priority class between clients using weighted fair queuing. Every job gets a virtual finish
+// time when it is queued: clients with a backlog have their finish times pushed further out, so a newly arriving client
+// is served right away instead of waiting behind all of another client's jobs. A client with weight w gets w times the
+// share of a client with weight 1.
+type fairQueue struct {
+	clients map[string][]scheduledJob
+	// lastFinish is the virtual finish time of each client's latest queued job.
+	lastFinish map[string]float64
+	// virtualTime is the virtual start time of the most recently dispatched job.
+	virtualTime float64
+	size        int
+}
+
+func newFairQueue() *fairQueue {
+	return &fairQueue{
+		clients:    make(map[string][]scheduledJob),
+		lastFinish: make(map[string]float64),
+	}
+}
+
+// push queues the job behind the client's earlier jobs and stamps its virtual start and finish times.
+func (queue *fairQueue) push(job scheduledJob, weight int) {
+	start := queue.virtualTime
+	if last, ok := queue.lastFinish[job.client]; ok && last > start {
+		start = last
+	}
+	job.start = start
+	job.finish = start + 1/float64(weight)
+	queue.lastFinish[job.client] = job.finish
+	queue.clients[job.client] = append(queue.clients[job.client], job)
+	queue.size++
+}
+
+// peek returns the client whose head job has the earliest virtual finish time. Ties go to the job queued first.
+func (queue *fairQueue) peek() (string, bool) {
+	var chosen string
+	found := false
+	for client, jobs := range queue.clients {
+		head := jobs[0]
+		if !found {
+			chosen, found = client, true
+			continue
+		}
+		best := queue.clients[chosen][0]
+		if head.finish < best.finish || (head.finish == best.finish && head.seq < best.seq) {
+			chosen = client
+		}
+	}
+	return chosen, found
+}
+
+// head returns the job that pop would return, without removing it.
+func (queue *fairQueue) head() (scheduledJob, bool) {
+	client, ok := queue.peek()
+	if !ok {
+		return scheduledJob{}, false
+	}
+	return queue.clients[client][0], true
+}
+
+// pop removes and returns the job with the earliest virtual finish time.
+func (queue *fairQueue) pop() (scheduledJob, bool) {
+	client, ok := queue.peek()
+	if !ok {
+		return scheduledJob{}, false
+	}
+	jobs := queue.clients[client]
+	job := jobs[0]
+	if len(jobs) == 1 {
+		delete(queue.clients, client)
+	} else {
+		queue.clients[client] = jobs[1:]
+	}
+	queue.virtualTime = job.start
+	queue.size--
+	// Forget idle clients whose finish times have fallen behind; they would restart from virtualTime anyway
+	for idle, last := range queue.lastFinish {
+		if _, queued := queue.clients[idle]; !queued && last <= queue.virtualTime {
+			delete(queue.lastFinish, idle)
+		}
+	}
+	return job, true
+}
+
+// clone copies the queue so dispatch order can be simulated without changing it.
+func (queue *fairQueue) clone() *fairQueue {
+	cloned := newFairQueue()
+	for client, jobs := range queue.clients {
+		cloned.clients[client] = append([]scheduledJob(nil), jobs...)
+	}
+	for client, last := range queue.lastFinish {
+		cloned.lastFinish[client] = last
+	}
+	cloned.virtualTime = queue.virtualTime
+	cloned.size = queue.size
+	return cloned
+}
+
+// remove takes the job with the given identifier out of the queue. The client's later jobs move up into its place,
+// so the client is not charged for a job that never ran.
+func (queue *fairQueue) remove(identifier int64) (scheduledJob, bool) {
+	for client, jobs := range queue.clients {
+		for i, job := range jobs {
+			if job.id != identifier {
+				continue
+			}
+			share := job.finish - job.start
+			for later := i + 1; later < len(jobs); later++ {
+				jobs[later].start -= share
+				jobs[later].finish -= share
+			}
+			if len(jobs) == 1 {
+				delete(queue.clients, client)
+			} else {
+				queue.clients[client] = append(jobs[:i:i], jobs[i+1:]...)
+			}
+			queue.lastFinish[client] -= share
+			queue.size--
+			return job, true
+		}
+	}
+	return scheduledJob{}, false
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fairShareHarness queues jobs for several clients in one priority class and records the order they are dispatched in.
+// It drives the queue directly, without workers or clocks, so the interleaving is fully deterministic.
+type fairShareHarness struct {
+	queue   *priorityQueue
+	weights map[string]int
+	nextId  int64
+	clients map[int64]string
+}
+
+func newFairShareHarness(weights map[string]int) *fairShareHarness {
+	return &fairShareHarness{
+		queue:   newPriorityQueue(PriorityPolicy{Strict: true}),
+		weights: weights,
+		clients: make(map[int64]string),
+	}
+}
+
+// submit queues count jobs for the client.
+func (harness *fairShareHarness) submit(client string, count int) {
+	for i := 0; i < count; i++ {
+		harness.nextId++
+		harness.clients[harness.nextId] = client
+		job := scheduledJob{id: harness.nextId, priority: PriorityNormal, client: client, enqueued: time.Now()}
+		harness.queue.push(job, harness.weights[client])
+	}
+}
+
+// dispatch pops count jobs and returns the clients they belonged to, e.g. "A B A B".
+func (harness *fairShareHarness) dispatch(count int) string {
+	var order []string
+	for i := 0; i < count; i++ {
+		job, ok := harness.queue.pop(time.Now())
+		if !ok {
+			break
+		}
+		order = append(order, harness.clients[job.id])
+	}
+	return strings.Join(order, " ")
+}
+
+func TestFairQueue_ClientsTakeTurns(t *testing.T) {
+	harness := newFairShareHarness(nil)
+	harness.submit("A", 6)
+	harness.submit("B", 3)
+	harness.submit("C", 1)
+	got := harness.dispatch(10)
+	want := "A B C A B A B A A A"
+	if got != want {
+		t.Error("Invalid interleaving. Want:", want, "Got:", got)
+	}
+}
+
+func TestFairQueue_LateClientDoesNotWaitBehindBacklog(t *testing.T) {
+	harness := newFairShareHarness(nil)
+	harness.submit("A", 100)
+	if got := harness.dispatch(3); got != "A A A" {
+		t.Error("Invalid dispatch order. Want: A A A Got:", got)
+	}
+	harness.submit("B", 2)
+	got := harness.dispatch(4)
+	if want := "B A B A"; got != want {
+		t.Error("Late client should be served right away. Want:", want, "Got:", got)
+	}
+}
+
+func TestFairQueue_Weights(t *testing.T) {
+	harness := newFairShareHarness(map[string]int{"A": 3})
+	harness.submit("A", 9)
+	harness.submit("B", 3)
+	got := strings.Fields(harness.dispatch(12))
+	want := strings.Fields("A A A B A A A B A A A B")
+	if !reflect.DeepEqual(got, want) {
+		t.Error("Weighted client should get three turns for each of the other client's. Want:", want, "Got:", got)
+	}
+}
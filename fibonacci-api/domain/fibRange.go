@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/dto"
+	"fibonacci-api/errs"
+	"math/big"
+	"strconv"
+)
+
+// DefaultMaxRange is the largest number of terms a range job may compute unless configured otherwise.
+const DefaultMaxRange = 10000
+
+// DefaultMaxRangeBytes is the largest estimated memory the terms of a range job may take unless configured otherwise.
+// Terms stay in memory, so this rather than the number of terms bounds ranges of large indices.
+const DefaultMaxRangeBytes = 256 << 20
+
+//RangeParameters are the first and last index of a range job and, once it completes, the terms F(From) to F(To). The
+//terms are never changed once stored, so copies of the sequence share them.
+type RangeParameters struct {
+	From  int64
+	To    int64
+	Terms []big.Int
+}
+
+// key identifies the range by its first and last index.
+func (params *RangeParameters) key() string {
+	return strconv.FormatInt(params.From, 10) + "_" + strconv.FormatInt(params.To, 10)
+}
+
+// deepCopy returns a copy of the parameters. The terms are shared, since they are not changed once stored.
+func (params *RangeParameters) deepCopy() *RangeParameters {
+	paramsCopy := *params
+	return &paramsCopy
+}
+
+// toRangeResponseDto converts the parameters into the response returned to the client, without any terms.
+func (params *RangeParameters) toRangeResponseDto() *dto.RangeResponse {
+	return &dto.RangeResponse{From: params.From, To: params.To, Total: params.To - params.From + 1}
+}
+
+// Page returns up to limit terms starting at the given offset from F(From). A limit of 0 returns every remaining term.
+// A range that has not completed has no terms.
+func (params *RangeParameters) Page(offset int, limit int) []dto.RangeTerm {
+	if offset >= len(params.Terms) {
+		return []dto.RangeTerm{}
+	}
+	end := len(params.Terms)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	page := make([]dto.RangeTerm, 0, end-offset)
+	for i := offset; i < end; i++ {
+		page = append(page, dto.RangeTerm{
+			Index: params.From + int64(i),
+			Fib:   &dto.Number{Value: &params.Terms[i], Format: dto.FormatJSONNumber},
+		})
+	}
+	return page
+}
+
+// rangeAlgorithm computes the terms of a range job. The first two terms are calculated directly with the algorithm the
+// client chose and the rest by addition, so a range costs about as much as its first term plus one addition per term.
+type rangeAlgorithm struct {
+	first Algorithm
+}
+
+func (a rangeAlgorithm) Name() string { return a.first.Name() }
+
+func (a rangeAlgorithm) Description() string {
+	return a.first.Description() + " The rest of the range by addition."
+}
+
+func (a rangeAlgorithm) MaxInput() int { return a.first.MaxInput() }
+
+func (a rangeAlgorithm) Compute(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	answer, _, err := a.computeAnnotated(ctx, sequence)
+	return answer, err
+}
+
+// computeAnnotated returns the last term of the range and records every term on the sequence's parameters.
+func (a rangeAlgorithm) computeAnnotated(ctx context.Context, sequence Sequence) (*big.Int, func(*Sequence), *errs.AppError) {
+	params := sequence.Range
+	if params == nil || params.To < params.From {
+		return nil, nil, errs.NewValidationError("Ranges need a first index no larger than their last index")
+	}
+	terms := make([]big.Int, params.To-params.From+1)
+	for i := 0; i < 2 && i < len(terms); i++ {
+		term := Sequence{Kind: KindFibonacci, Algo: sequence.Algo, Multiply: sequence.Multiply, Input: int(params.From) + i + 1}
+		answer, err := a.first.Compute(ctx, term)
+		if err != nil {
+			return nil, nil, err
+		}
+		terms[i].Set(term.signed(answer))
+	}
+	for i := 2; i < len(terms); i++ {
+		if i%cancelCheckInterval == 0 {
+			if err := cancelled(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+		terms[i].Add(&terms[i-1], &terms[i-2])
+	}
+	return &terms[len(terms)-1], func(sequence *Sequence) {
+		if sequence.Range != nil {
+			sequence.Range.Terms = terms
+		}
+	}, nil
+}
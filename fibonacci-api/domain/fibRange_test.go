@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestFibRepositoryMap_Range(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	for _, algorithm := range []string{"doubling", "iterate", "auto"} {
+		sequence := Sequence{Kind: KindRange, Algo: algorithm, Input: -5, Range: &RangeParameters{From: -5, To: 2000}}
+		result, err := repo.CalculateFib(context.Background(), sequence, wg)
+		if err != nil {
+			t.Error("Error was returned while calling CalculateFib: ", err)
+			return
+		}
+		wg.Wait()
+		stored, _ := repo.FindBy(result.Id)
+		if stored.Status != StatusComplete || len(stored.Range.Terms) != 2006 {
+			t.Error("Invalid range job with", algorithm, "Want:", StatusComplete, 2006, "Got:", stored.Status, len(stored.Range.Terms))
+			continue
+		}
+		// F(-5) = 5, F(-4) = -3, ..., F(0) = 0, F(1) = 1
+		for i, want := range []int64{5, -3, 2, -1, 1, 0, 1, 1, 2} {
+			if stored.Range.Terms[i].Cmp(big.NewInt(want)) != 0 {
+				t.Error("Invalid range term with", algorithm, "for index", i-5, "Want:", want, "Got:", &stored.Range.Terms[i])
+			}
+		}
+		if last := &stored.Range.Terms[2005]; last.Cmp(doubling(context.Background(), 2000)) != 0 {
+			t.Error("Invalid last range term with", algorithm)
+		}
+		if _, err := repo.Result(result.Id); err == nil {
+			t.Error("Expected an error when asking a range job for a single result")
+		}
+	}
+	if stats := repo.CacheStats(); stats.Entries != 0 {
+		t.Error("Range jobs should bypass the result cache. Got entries:", stats.Entries)
+	}
+}
+
+func TestRangeParameters_Page(t *testing.T) {
+	params := &RangeParameters{From: 10, To: 14, Terms: make([]big.Int, 5)}
+	page := params.Page(3, 10)
+	if len(page) != 2 || page[0].Index != 13 || page[1].Index != 14 {
+		t.Error("Invalid page of range terms. Want: indices 13 and 14 Got:", page)
+	}
+	if page := params.Page(1, 2); len(page) != 2 || page[0].Index != 11 {
+		t.Error("Invalid page of range terms. Want: indices 11 and 12 Got:", page)
+	}
+	if page := params.Page(5, 10); len(page) != 0 {
+		t.Error("Pages past the end of the range should be empty. Got:", page)
+	}
+	if page := params.Page(0, 0); len(page) != 5 {
+		t.Error("A limit of 0 should return every term. Got:", len(page))
+	}
+}
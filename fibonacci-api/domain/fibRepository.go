@@ -1,171 +1,657 @@
 package domain
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fibonacci-api/errs"
-	"math"
+	"io"
 	"math/big"
+	"math/bits"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
+//FibRepositoryMap is the concurrency-safe FibRepository. Sequences live in a sharded SequenceStore, and every read
+//returns a deep copy so handlers never share big.Int memory with running algorithms.
 type FibRepositoryMap struct {
-	Sequences map[int64]Sequence
+	Sequences  *SequenceStore
+	Registry   *AlgorithmRegistry
+	Scheduler  *Scheduler
+	Cache      *ResultCache
+	JobTimeout time.Duration
+	// Checkpoints backs the "checkpoint" algorithm.
+	Checkpoints *CheckpointStore
+	// Planner picks the algorithm for sequences asking for "auto".
+	Planner *Planner
+	// Results keeps large results on disk. Nil keeps every result in memory.
+	Results *ResultStore
+	flights *flightGroup
 }
 
-// Define and keep track of the sequence ids
-//source: https://stackoverflow.com/questions/27917750/how-to-define-a-global-counter-in-golang-http-server
-var id int64 = 0
+// transition moves the stored sequence to the next status after applying update to it. The check and the change
+// happen under the sequence's shard lock, so a job finishing and a client cancelling it cannot both win.
+func (fibRepo FibRepositoryMap) transition(identifier int64, next Status, update func(*Sequence)) *errs.AppError {
+	return fibRepo.Sequences.Update(identifier, func(sequence *Sequence) *errs.AppError {
+		if err := sequence.Transition(next, time.Now()); err != nil {
+			return err
+		}
+		if update != nil {
+			update(sequence)
+		}
+		return nil
+	})
+}
 
-// incId increments the number of the incrementing identifier and returns the new value
-func incId() int64 {
-	return atomic.AddInt64(&id, 1)
+// UpdateFib updates the FibRepositoryMap after the sequence is done being calculated.
+func (fibRepo FibRepositoryMap) UpdateFib(identifier int64, fibNumber big.Int, duration time.Duration) *errs.AppError {
+	return fibRepo.transition(identifier, StatusComplete, func(sequence *Sequence) {
+		sequence.Fib = fibNumber
+		sequence.Duration = duration.Microseconds()
+	})
 }
 
-// getId returns the current value of the incrementing identifier
-func getId() int64 {
-	return atomic.LoadInt64(&id)
+// UpdateFibFile completes the sequence with a result that was written to disk, keeping only a reference to the file.
+func (fibRepo FibRepositoryMap) UpdateFibFile(identifier int64, resultFile string, resultBits int, duration time.Duration) *errs.AppError {
+	return fibRepo.transition(identifier, StatusComplete, func(sequence *Sequence) {
+		sequence.ResultFile = resultFile
+		sequence.ResultBits = resultBits
+		sequence.Duration = duration.Microseconds()
+	})
 }
 
-// UpdateFib updates the FibRepositoryMap after the sequence is done being calculated.
-func (fibRepo FibRepositoryMap) UpdateFib(identifier int64, fibNumber big.Int, duration time.Duration) {
-	tempSequence := fibRepo.Sequences[identifier]
-	tempSequence.Fib = fibNumber
-	tempSequence.Duration = duration.Microseconds()
-	tempSequence.Status = "complete"
-	fibRepo.Sequences[identifier] = tempSequence
+// StopFib moves the sequence to a failed, cancelled or timed out status and records why it did not complete.
+func (fibRepo FibRepositoryMap) StopFib(identifier int64, status Status, duration time.Duration, reason string) *errs.AppError {
+	return fibRepo.transition(identifier, status, func(sequence *Sequence) {
+		sequence.Duration = duration.Microseconds()
+		sequence.FailureReason = reason
+	})
 }
 
-// FindBy finds the sequence by its identifier and
+// storeResult writes the sequence's result to disk if it is large enough and returns the file name, or "" if the
+// number should be kept in memory. Numbers that cannot be written are kept in memory too, and so are ranges, whose
+// terms are all kept in memory.
+func (fibRepo FibRepositoryMap) storeResult(sequence Sequence, answer *big.Int) string {
+	if sequence.Range != nil || !fibRepo.Results.ShouldStore(answer) {
+		return ""
+	}
+	resultFile, _ := fibRepo.Results.Save(resultFileName(sequence), answer)
+	return resultFile
+}
+
+// summarize returns the number of decimal digits of the answer and the SHA-256 of the digits as served by
+// /find/{id}/digits, so /find can report them without converting the number on every request. The decimal text of a
+// result stored on disk is written next to it as well, since it has just been converted anyway.
+func (fibRepo FibRepositoryMap) summarize(answer *big.Int, resultFile string) (int, string) {
+	decimal := answer.String()
+	checksum := sha256.Sum256([]byte(decimal))
+	if resultFile != "" {
+		fibRepo.Results.write(textFileName(resultFile, 10), func(file io.Writer) error {
+			_, err := io.WriteString(file, decimal)
+			return err
+		})
+	}
+	return len(strings.TrimPrefix(decimal, "-")), hex.EncodeToString(checksum[:])
+}
+
+// recordDigest stores the number of decimal digits of the sequence's result and their SHA-256.
+func (fibRepo FibRepositoryMap) recordDigest(identifier int64, digits int, checksum string) {
+	fibRepo.Sequences.Update(identifier, func(sequence *Sequence) *errs.AppError {
+		sequence.Digits = digits
+		sequence.SHA256 = checksum
+		return nil
+	})
+}
+
+// recordVerification stores the outcome of checking the sequence's result.
+func (fibRepo FibRepositoryMap) recordVerification(identifier int64, verification Verification, duration time.Duration) {
+	fibRepo.Sequences.Update(identifier, func(sequence *Sequence) *errs.AppError {
+		sequence.Verification = verification
+		sequence.VerificationDuration = duration.Microseconds()
+		return nil
+	})
+}
+
+// verify checks answer with VerifyFib and reports the outcome, how long the check took and, on a mismatch, the
+// diagnostic to fail the sequence with.
+func verify(n uint64, answer *big.Int) (Verification, time.Duration, *errs.AppError) {
+	startTime := time.Now()
+	if err := VerifyFib(n, answer); err != nil {
+		return VerificationFailed, time.Since(startTime), err
+	}
+	return VerificationPassed, time.Since(startTime), nil
+}
+
+// Cancel stops the calculation for the given identifier and moves the sequence to the cancelled status.
+func (fibRepo FibRepositoryMap) Cancel(identifier int64) (*Sequence, *errs.AppError) {
+	appError := fibRepo.transition(identifier, StatusCancelled, func(sequence *Sequence) {
+		sequence.FailureReason = "Cancelled by client"
+	})
+	if appError != nil {
+		return nil, appError
+	}
+	if leader, abandoned := fibRepo.flights.detach(identifier); abandoned {
+		// A job still in the queue would hold its place there until a worker reached it, so it is taken out now and run
+		// right away: it sees that every sequence left and only releases the computation
+		if run, queued := fibRepo.Scheduler.Remove(leader); queued {
+			run()
+		}
+	}
+	return fibRepo.FindBy(identifier)
+}
+
+// FindBy finds the sequence by its identifier and returns a deep copy of it.
 func (fibRepo FibRepositoryMap) FindBy(identifier int64) (*Sequence, *errs.AppError) {
-	sequence, sequencePresent := fibRepo.Sequences[identifier]
+	sequence, sequencePresent := fibRepo.Sequences.Get(identifier)
 	if !sequencePresent {
 		appError := errs.NewValidationError("There is no sequence with the given identifier")
 		return nil, appError
 	}
-	response := Sequence{
-		Fib:      sequence.Fib,
-		Duration: sequence.Duration,
-		Algo:     sequence.Algo,
-		Input:    sequence.Input,
-		Status:   sequence.Status,
-		Id:       sequence.Id,
-	}
-	return &response, nil
+	return &sequence, nil
 }
 
-// CalculateFib takes in the input and the algorithm and delegates the work to calculate the sequence to the proper function
-func (fibRepo FibRepositoryMap) CalculateFib(sequence Sequence, wg *sync.WaitGroup) (Sequence, *errs.AppError) {
-	incId()
-	sequenceId := getId()
-	sequence.Id = sequenceId
-	fibRepo.Sequences[sequenceId] = sequence
-	startTime := time.Now()
-	if sequence.Algo == "iterate" {
-		go fibRepo.IterateFib(int64(sequence.Input), sequenceId, startTime, wg)
+// completed finds the sequence by its identifier and checks that it is complete with a single result.
+func (fibRepo FibRepositoryMap) completed(identifier int64) (*Sequence, *errs.AppError) {
+	sequence, appError := fibRepo.FindBy(identifier)
+	if appError != nil {
+		return nil, appError
 	}
-	if sequence.Algo == "recursive" {
-		var numMap = map[int64]*big.Int{0: big.NewInt(0), 1: big.NewInt(1)}
-		//account for zero indexing
-		tempInput := sequence.Input - 1
-		go fibRepo.RecurseFib(int64(tempInput), numMap, startTime, 1, wg)
+	if sequence.Status != StatusComplete {
+		return nil, errs.NewValidationError("Sequence is not complete. Status: " + string(sequence.Status))
 	}
-	if sequence.Algo == "math" {
-		go fibRepo.MathFib(int64(sequence.Input), startTime, wg)
+	if sequence.Range != nil {
+		return nil, errs.NewValidationError("Range jobs have no single result, only their terms")
 	}
 	return sequence, nil
 }
 
-// IterateFib uses dynamic programming to iteratively calculate the sequence.
-func (fibRepo FibRepositoryMap) IterateFib(input int64, id int64, startTime time.Time, wg *sync.WaitGroup) *errs.AppError {
+// Result returns the computed number of a complete sequence, reading it from the results directory if it was stored
+// there.
+func (fibRepo FibRepositoryMap) Result(identifier int64) (*big.Int, *errs.AppError) {
+	sequence, appError := fibRepo.completed(identifier)
+	if appError != nil {
+		return nil, appError
+	}
+	if sequence.ResultFile != "" {
+		return fibRepo.Results.Load(sequence.ResultFile)
+	}
+	return &sequence.Fib, nil
+}
+
+// textReader serves the text of a result kept in memory.
+type textReader struct {
+	*strings.Reader
+}
+
+func (reader textReader) Close() error { return nil }
+
+// ResultText returns a reader over the computed number of a complete sequence written in the given base. Results in the
+// results directory are read from their text file there, which is written once per base, so they are never held in
+// memory as a whole. The caller must close the reader.
+func (fibRepo FibRepositoryMap) ResultText(identifier int64, base int) (io.ReadSeekCloser, *errs.AppError) {
+	sequence, appError := fibRepo.completed(identifier)
+	if appError != nil {
+		return nil, appError
+	}
+	if sequence.ResultFile != "" {
+		return fibRepo.Results.Text(sequence.ResultFile, base)
+	}
+	return textReader{strings.NewReader(sequence.Fib.Text(base))}, nil
+}
+
+// Algorithms returns the algorithms registered with the repository.
+func (fibRepo FibRepositoryMap) Algorithms() []Algorithm {
+	return fibRepo.Registry.All()
+}
+
+// QueueStatus returns the scheduler's load and the queue position of the job computing the sequence with the given
+// identifier.
+func (fibRepo FibRepositoryMap) QueueStatus(identifier int64) QueueStatus {
+	return fibRepo.Scheduler.Status(fibRepo.flights.leaderOf(identifier))
+}
+
+// CalculateFib takes in the input and the algorithm and hands the work to calculate the sequence to the scheduler. The
+// calculation stops early when ctx is cancelled, when the repository's JobTimeout passes after a worker started it or
+// when the sequence is cancelled through Cancel. If the scheduler rejects the job the sequence is not stored and its error is returned.
+// Requests for a number that is already being computed with the same algorithm attach to that computation instead of
+// starting another one. Sequences of other kinds, such as Lucas sequences, linear recurrences and ranges, run as the same
+// kind of job but bypass the result cache, which only holds fibonacci numbers. Races are never answered from the cache
+// either, since they exist to time the algorithms.
+func (fibRepo FibRepositoryMap) CalculateFib(ctx context.Context, sequence Sequence, wg *sync.WaitGroup) (Sequence, *errs.AppError) {
+	if sequence.Kind == "" {
+		sequence.Kind = KindFibonacci
+	}
+	algorithm, appError := fibRepo.algorithmFor(&sequence)
+	if appError != nil {
+		return sequence, appError
+	}
+	sequenceId := fibRepo.Sequences.NextId()
+	sequence.Id = sequenceId
+	sequence.Status = StatusQueued
+	if sequence.Priority == "" {
+		sequence.Priority = PriorityNormal
+	}
+	sequence.CreatedAt = time.Now()
+	if sequence.Verify {
+		sequence.Verification = VerificationPending
+	}
+	if sequence.isFibonacci() && sequence.Algo != RaceAlgorithm {
+		if answer, hit := fibRepo.Cache.Get(sequence.index()); hit {
+			return fibRepo.completeFromCache(sequence, answer), nil
+		}
+	}
+	// The job can be cancelled while it waits in the queue, but its JobTimeout only starts once a worker runs it
+	jobCtx, cancel := context.WithCancel(ctx)
+	f, leader := fibRepo.flights.join(sequence, cancel, func(running bool) {
+		fibRepo.Sequences.Put(sequence)
+		if running {
+			fibRepo.transition(sequenceId, StatusRunning, nil)
+		}
+	})
+	if !leader {
+		cancel()
+		return sequence, nil
+	}
+	fibRepo.Sequences.Put(sequence)
 	// Ensures graceful shutdown
 	wg.Add(1)
+	appError = fibRepo.Scheduler.Submit(sequenceId, sequence.Priority, sequence.Client, func() {
+		runCtx := jobCtx
+		if fibRepo.JobTimeout > 0 {
+			var cancelTimeout context.CancelFunc
+			runCtx, cancelTimeout = context.WithTimeout(jobCtx, fibRepo.JobTimeout)
+			defer cancelTimeout()
+		}
+		fibRepo.runAlgorithm(runCtx, algorithm, sequence, f, wg)
+	})
+	if appError != nil {
+		wg.Done()
+		for _, identifier := range fibRepo.flights.finish(f) {
+			// Requests that attached in the meantime already have their id, so they fail instead of disappearing
+			fibRepo.StopFib(identifier, StatusFailed, 0, appError.Message)
+		}
+		fibRepo.Sequences.Delete(sequenceId)
+		return sequence, appError
+	}
+	return sequence, nil
+}
+
+// algorithmFor returns the algorithm that computes the sequence. Fibonacci sequences use the registered algorithm they
+// name, with "auto" resolved to the planner's choice, which is recorded on the sequence, and so do ranges, for their
+// first terms. Other kinds have their own.
+func (fibRepo FibRepositoryMap) algorithmFor(sequence *Sequence) (Algorithm, *errs.AppError) {
+	switch sequence.Kind {
+	case KindLucas:
+		sequence.Algo = LucasAlgorithm
+		return lucasAlgorithm{}, nil
+	case KindRecurrence:
+		sequence.Algo = RecurrenceAlgorithm
+		return recurrenceAlgorithm{}, nil
+	}
+	algorithm, ok := fibRepo.Registry.Get(sequence.Algo)
+	if !ok {
+		return nil, fibRepo.Registry.UnknownAlgorithmError(sequence.Algo)
+	}
+	if sequence.Algo == AutoAlgorithm {
+		chosen, reason, appError := fibRepo.Planner.Choose(fibRepo.Registry.concrete(), *sequence)
+		if appError != nil {
+			return nil, appError
+		}
+		algorithm = chosen
+		sequence.Algo = chosen.Name()
+		sequence.SelectionReason = reason
+	}
+	if sequence.Kind == KindRange {
+		return rangeAlgorithm{first: algorithm}, nil
+	}
+	return algorithm, nil
+}
+
+// completeFromCache stores the sequence as already complete, answered from the result cache without queueing a job.
+func (fibRepo FibRepositoryMap) completeFromCache(sequence Sequence, answer *big.Int) Sequence {
+	now := time.Now()
+	sequence.Transition(StatusRunning, now)
+	sequence.Duration = 0
+	sequence.Cached = true
+	if sequence.Verify {
+		var verificationDuration time.Duration
+		var appError *errs.AppError
+		sequence.Verification, verificationDuration, appError = verify(sequence.index(), answer)
+		sequence.VerificationDuration = verificationDuration.Microseconds()
+		if appError != nil {
+			sequence.Transition(StatusFailed, time.Now())
+			sequence.FailureReason = appError.Message
+			fibRepo.Sequences.Put(sequence)
+			return sequence
+		}
+	}
+	sequence.Transition(StatusComplete, now)
+	answer = sequence.signed(answer)
+	resultFile := fibRepo.storeResult(sequence, answer)
+	if resultFile != "" {
+		sequence.ResultFile = resultFile
+		sequence.ResultBits = answer.BitLen()
+	} else {
+		sequence.Fib = *answer
+	}
+	sequence.Digits, sequence.SHA256 = fibRepo.summarize(answer, resultFile)
+	fibRepo.Sequences.Put(sequence)
+	return sequence
+}
+
+// CacheStats returns the result cache's hit and miss counters and its size.
+func (fibRepo FibRepositoryMap) CacheStats() CacheStats {
+	return fibRepo.Cache.Stats()
+}
+
+// runAlgorithm moves the sequences attached to the flight to running, computes the result once with the given
+// algorithm and then moves every attached sequence to the terminal status matching how the algorithm finished. All of
+// them share the same stored big.Int. A failed final transition means the client cancelled that sequence first, which
+// already recorded its status.
+func (fibRepo FibRepositoryMap) runAlgorithm(ctx context.Context, algorithm Algorithm, sequence Sequence, f *flight, wg *sync.WaitGroup) {
 	defer wg.Done()
-	if input <= 1 {
-		duration := time.Now().Sub(startTime)
-		answer := *big.NewInt(input)
-		fibRepo.UpdateFib(id, answer, duration)
-		return nil
+	startTime := time.Now()
+	started := fibRepo.flights.start(f, func(identifier int64) {
+		fibRepo.transition(identifier, StatusRunning, nil)
+	})
+	if !started {
+		// Every attached sequence was cancelled before it started
+		fibRepo.flights.finish(f)
+		return
+	}
+	var answer *big.Int
+	var annotate func(*Sequence)
+	var err *errs.AppError
+	if annotated, ok := algorithm.(annotatedAlgorithm); ok {
+		answer, annotate, err = annotated.computeAnnotated(ctx, sequence)
+	} else {
+		answer, err = algorithm.Compute(ctx, sequence)
+	}
+	//Find time taken and update repo
+	duration := time.Since(startTime)
+	ctxErr := ctx.Err()
+	var verification Verification
+	var verificationDuration time.Duration
+	if sequence.Verify && ctxErr == nil && err == nil {
+		verification, verificationDuration, err = verify(sequence.index(), answer)
+	}
+	if ctxErr == nil && err == nil && sequence.isFibonacci() {
+		// The cache answers every algorithm, so it only takes results it can trust: those of built-in algorithms,
+		// including a race won by one, and verified ones. Caching before the flight ends lets later requests find the
+		// result in one place or the other
+		computedBy := sequence
+		if annotate != nil {
+			annotate(&computedBy)
+		}
+		if fibRepo.Registry.BuiltIn(computedBy.Algo) || verification == VerificationPassed {
+			fibRepo.Cache.Add(sequence.index(), answer)
+		}
+		fibRepo.Planner.Observe(algorithm.Name(), sequence.index(), duration)
+	}
+	var resultFile string
+	var digits int
+	var checksum string
+	if ctxErr == nil && err == nil {
+		answer = sequence.signed(answer)
+		resultFile = fibRepo.storeResult(sequence, answer)
+		if sequence.Range == nil {
+			digits, checksum = fibRepo.summarize(answer, resultFile)
+		}
+	}
+	for _, identifier := range fibRepo.flights.finish(f) {
+		if verification != "" {
+			fibRepo.recordVerification(identifier, verification, verificationDuration)
+		}
+		if checksum != "" {
+			fibRepo.recordDigest(identifier, digits, checksum)
+		}
+		if annotate != nil {
+			fibRepo.Sequences.Update(identifier, func(sequence *Sequence) *errs.AppError {
+				annotate(sequence)
+				return nil
+			})
+		}
+		switch {
+		case errors.Is(ctxErr, context.DeadlineExceeded):
+			fibRepo.StopFib(identifier, StatusTimedOut, duration, "Calculation did not finish within "+fibRepo.JobTimeout.String())
+		case ctxErr != nil:
+			fibRepo.StopFib(identifier, StatusCancelled, duration, "Calculation was cancelled")
+		case err != nil:
+			fibRepo.StopFib(identifier, StatusFailed, duration, err.Message)
+		case resultFile != "":
+			fibRepo.UpdateFibFile(identifier, resultFile, answer.BitLen(), duration)
+		default:
+			fibRepo.UpdateFib(identifier, *answer, duration)
+		}
+	}
+}
+
+// cancelCheckInterval is how many steps the linear algorithms take between checks for cancellation.
+const cancelCheckInterval = 1024
+
+// cancelled returns an error if ctx has been cancelled.
+func cancelled(ctx context.Context) *errs.AppError {
+	if err := ctx.Err(); err != nil {
+		return errs.NewUnexpectedError("Calculation was stopped: " + err.Error())
+	}
+	return nil
+}
+
+// IterateFib uses dynamic programming to iteratively calculate the sequence.
+func IterateFib(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	n := sequence.index()
+	if n <= 1 {
+		return new(big.Int).SetUint64(n), nil
 	}
 	//Create our cache
-	numDict := make([]big.Int, input+1)
+	numDict := make([]big.Int, n+1)
 	numDict[1] = *big.NewInt(1)
 	//Build result iteratively
-	for i := int64(2); i < input; i++ {
+	for i := uint64(2); i <= n; i++ {
+		if i%cancelCheckInterval == 0 {
+			if err := cancelled(ctx); err != nil {
+				return nil, err
+			}
+		}
 		numDict[i] = *new(big.Int).Add(&numDict[i-1], &numDict[i-2])
 	}
-	//Find time taken and update repo
-	duration := time.Now().Sub(startTime)
-	fibRepo.UpdateFib(id, numDict[input-1], duration)
-	return nil
+	return &numDict[n], nil
 }
 
-// RecurseFib uses recursion and memoization to recursively calculate the sequence.
-func (fibRepo FibRepositoryMap) RecurseFib(input int64, numMap map[int64]*big.Int, startTime time.Time, recursionDepth int, wg *sync.WaitGroup) *big.Int {
-	// Ensures graceful shutdown
-	wg.Add(1)
-	defer wg.Done()
-	//memoization
-	value, keyPresent := numMap[input]
-	if keyPresent {
-		return value
+// RecurseFib calculates the sequence top-down with memoization. The recursion runs on an explicit stack of indices
+// rather than the call stack, and only the two most recent terms are memoized, so memory stays at O(n) words for the
+// stack plus two numbers instead of n goroutine stack frames and n numbers.
+func RecurseFib(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	answer := recurse(ctx, sequence.index())
+	if err := cancelled(ctx); err != nil {
+		return nil, err
 	}
-	//recurse
-	numMap[input] = new(big.Int).Add(fibRepo.RecurseFib(input-1, numMap, startTime, recursionDepth+1, wg), fibRepo.RecurseFib(input-2, numMap, startTime, recursionDepth+1, wg))
-	//Find time taken and update repo
-	if recursionDepth == 1 {
-		duration := time.Since(startTime)
-		fibRepo.UpdateFib(id, *numMap[input], duration)
+	return answer, nil
+}
+
+// recurse returns F(n). Each frame on the stack waits for F(k-1) and F(k-2); once both are memoized F(k) is written
+// over F(k-2), which no remaining frame needs. Once ctx is cancelled it stops without computing the remaining terms.
+func recurse(ctx context.Context, n uint64) *big.Int {
+	memo := map[uint64]*big.Int{0: big.NewInt(0), 1: big.NewInt(1)}
+	stack := []uint64{n}
+	for len(stack) > 0 {
+		k := stack[len(stack)-1]
+		if _, done := memo[k]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if k%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return new(big.Int)
+		}
+		previous, ready := memo[k-1]
+		if !ready {
+			//recurse
+			stack = append(stack, k-1)
+			continue
+		}
+		older := memo[k-2]
+		delete(memo, k-2)
+		memo[k] = older.Add(previous, older)
+		stack = stack[:len(stack)-1]
 	}
-	return numMap[input]
+	return memo[n]
 }
 
-//MathFib uses the golden ratio (Binet's formula) to calculate the nth fibonacci number.
-func (fibRepo FibRepositoryMap) MathFib(input int64, startTime time.Time, wg *sync.WaitGroup) *errs.AppError {
-	// Ensures graceful shutdown
-	wg.Add(1)
-	defer wg.Done()
-	//need to adjust for zero indexing
-	input -= 1
-	//Define the golden ratio
-	divisor := big.NewFloat(2)
-	dividend := big.NewFloat(1 + math.Sqrt(5))
-	var goldenRatio = new(big.Float).Quo(dividend, divisor)
-	//raise the golden ratio by our input and divide by sqrt 5
-	unrounded := new(big.Float).Quo(Pow(goldenRatio, uint64(input)), new(big.Float).Sqrt(big.NewFloat(5)))
-	// Round and convert our answer
-	delta := 0.5
-	unrounded.Add(unrounded, big.NewFloat(delta))
-	rounded, _ := unrounded.Int(nil)
-	answer := rounded
-	//Find time taken and update repo
-	duration := time.Since(startTime)
-	fibRepo.UpdateFib(id, *answer, duration)
-	return nil
+//MathFib uses the golden ratio (Binet's formula) to calculate the nth fibonacci number. The working precision grows
+// with n so the rounded result is exact, and the result is checked against the Lucas identity L(n)^2 - 5F(n)^2 =
+// 4(-1)^n before the sequence is marked complete.
+func MathFib(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	answer, exact := binet(ctx, sequence.index())
+	if err := cancelled(ctx); err != nil {
+		return nil, err
+	}
+	if !exact {
+		return nil, errs.NewUnexpectedError("Binet's formula could not produce an exact result")
+	}
+	return answer, nil
+}
+
+// DoublingFib uses the fast doubling identities F(2k) = F(k)(2F(k+1) - F(k)) and F(2k+1) = F(k)^2 + F(k+1)^2 to
+// calculate the sequence with O(log n) big multiplications.
+func DoublingFib(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	answer := doubling(ctx, sequence.index())
+	if err := cancelled(ctx); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// doubling returns F(n). It stops early, returning a partial value, once ctx is cancelled.
+func doubling(ctx context.Context, n uint64) *big.Int {
+	a, _ := doublingPair(ctx, n)
+	return a
+}
+
+// doublingPair walks the bits of n from the most significant down, keeping only the pair (F(k), F(k+1)) and two
+// scratch values, and returns (F(n), F(n+1)). It stops early, returning partial values, once ctx is cancelled.
+func doublingPair(ctx context.Context, n uint64) (*big.Int, *big.Int) {
+	a := big.NewInt(0) // F(k)
+	b := big.NewInt(1) // F(k+1)
+	t1 := new(big.Int)
+	t2 := new(big.Int)
+	for i := bits.Len64(n) - 1; i >= 0 && ctx.Err() == nil; i-- {
+		// F(2k) = F(k) * (2F(k+1) - F(k))
+		t1.Lsh(b, 1)
+		t1.Sub(t1, a)
+		t1.Mul(t1, a)
+		// F(2k+1) = F(k)^2 + F(k+1)^2
+		t2.Mul(b, b)
+		a.Mul(a, a)
+		t2.Add(t2, a)
+		a, t1 = t1, a
+		b, t2 = t2, b
+		if n>>uint(i)&1 == 1 {
+			// Step forward: (F(2k), F(2k+1)) -> (F(2k+1), F(2k+2))
+			t1.Add(a, b)
+			a, b, t1 = b, t1, a
+		}
+	}
+	return a, b
+}
+
+// MatrixFib raises the Q-matrix [1 1; 1 0] to the nth power by repeated squaring, using the multiplier named by the
+// sequence for the 2x2 products.
+func MatrixFib(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	multiply, ok := MatrixMultipliers[sequence.Multiply]
+	if !ok {
+		multiply = NaiveMultiply
+	}
+	answer := matrixPow(ctx, sequence.index(), multiply)
+	if err := cancelled(ctx); err != nil {
+		return nil, err
+	}
+	return answer, nil
 }
 
 // NewFibRepository creates a new FibRepo.
 func NewFibRepository() FibRepositoryMap {
+	checkpoints := NewCheckpointStore(DefaultCheckpointSpacing, DefaultCheckpointBytes)
+	registry := NewAlgorithmRegistry()
+	for _, algorithm := range append(DefaultAlgorithms(), NewCheckpointAlgorithm(checkpoints)) {
+		registry.RegisterBuiltIn(algorithm)
+	}
+	planner := NewPlanner(DefaultCostModels())
+	scheduler := NewDefaultScheduler()
+	registry.Register(NewAutoAlgorithm(registry, planner))
+	registry.Register(NewRaceAlgorithm(registry, planner, scheduler))
 	return FibRepositoryMap{
-		Sequences: make(map[int64]Sequence),
+		Sequences:   NewSequenceStore(DefaultShardCount),
+		Registry:    registry,
+		Scheduler:   scheduler,
+		Cache:       NewResultCache(DefaultCacheBytes),
+		Checkpoints: checkpoints,
+		Planner:     planner,
+		flights:     newFlightGroup(),
 	}
 }
 
-//Source: https://steemit.com/tutorial/@gopher23/power-and-root-functions-using-big-float-in-golang
-func Pow(a *big.Float, e uint64) *big.Float {
-	result := Zero().Copy(a)
-	for i := uint64(0); i < e-1; i++ {
-		result = Mul(result, a)
+// binetPrecision returns the number of mantissa bits needed to hold F(n) exactly: about n*log2(phi) bits for the
+// integer part plus guard bits to absorb the rounding error that builds up while raising phi to the nth power.
+func binetPrecision(n uint64) uint {
+	const log2Phi = 0.6942419136306174
+	return uint(float64(n)*log2Phi) + 2*uint(bits.Len64(n)) + 64
+}
+
+// binet computes F(n) = round(phi^n / sqrt(5)) and L(n) = round(phi^n + psi^n) at a precision that grows with n. The
+// second return value reports whether the pair satisfies L(n)^2 - 5F(n)^2 = 4(-1)^n, which only holds for true
+// Fibonacci/Lucas pairs, so a false value means the rounding cannot be trusted. Cancelling ctx stops the calculation
+// between its steps.
+func binet(ctx context.Context, n uint64) (*big.Int, bool) {
+	prec := binetPrecision(n)
+	sqrt5 := new(big.Float).SetPrec(prec).SetInt64(5)
+	sqrt5.Sqrt(sqrt5)
+	//Define the golden ratio
+	goldenRatio := new(big.Float).SetPrec(prec).SetInt64(1)
+	goldenRatio.Add(goldenRatio, sqrt5)
+	goldenRatio.Quo(goldenRatio, big.NewFloat(2))
+	//raise the golden ratio by our input and divide by sqrt 5
+	if ctx.Err() != nil {
+		return nil, false
 	}
-	return result
+	phiN := Pow(goldenRatio, n)
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	fib := round(new(big.Float).SetPrec(prec).Quo(phiN, sqrt5))
+	// psi^n = (-1)^n / phi^n
+	psiN := new(big.Float).SetPrec(prec).Quo(big.NewFloat(1), phiN)
+	if n%2 == 1 {
+		psiN.Neg(psiN)
+	}
+	lucas := round(new(big.Float).SetPrec(prec).Add(phiN, psiN))
+	// Exactness check: L^2 - 5F^2 must equal 4(-1)^n
+	check := new(big.Int).Mul(lucas, lucas)
+	check.Sub(check, new(big.Int).Mul(big.NewInt(5), new(big.Int).Mul(fib, fib)))
+	want := big.NewInt(4)
+	if n%2 == 1 {
+		want.Neg(want)
+	}
+	return fib, check.Cmp(want) == 0
 }
 
-func Zero() *big.Float {
-	r := big.NewFloat(0.0)
-	r.SetPrec(256)
-	return r
+// round rounds a non-negative float to the nearest integer.
+func round(f *big.Float) *big.Int {
+	half := new(big.Float).SetPrec(f.Prec()).SetFloat64(0.5)
+	rounded, _ := half.Add(f, half).Int(nil)
+	return rounded
 }
 
-func Mul(a, b *big.Float) *big.Float {
-	return Zero().Mul(a, b)
+// Pow raises a to the power e using exponentiation by squaring, at the precision of a.
+func Pow(a *big.Float, e uint64) *big.Float {
+	result := new(big.Float).SetPrec(a.Prec()).SetInt64(1)
+	base := new(big.Float).SetPrec(a.Prec()).Set(a)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result.Mul(result, base)
+		}
+		base.Mul(base, base)
+	}
+	return result
 }
@@ -1,9 +1,12 @@
 package domain
 
 import (
+	"context"
+	"fibonacci-api/errs"
 	"math/big"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -15,21 +18,22 @@ func TestFibRepositoryMap_UpdateFib(t *testing.T) {
 		Duration: -1,
 		Algo:     "math",
 		Input:    3,
-		Status:   "incomplete",
+		Status:   StatusRunning,
+		Id:       1,
 	}
 
 	// Set then update sequence.
-	repo.Sequences[1] = testSequence
+	repo.Sequences.Put(testSequence)
 	repo.UpdateFib(1, *big.NewInt(2), 99)
 
 	// Check to make sure it's been updated correctly
-	updatedSequence := repo.Sequences[1]
+	updatedSequence, _ := repo.Sequences.Get(1)
 	comparisonSequence := Sequence{
 		Fib:      *big.NewInt(2),
 		Duration: 99,
 		Algo:     "math",
 		Input:    3,
-		Status:   "complete",
+		Status:   StatusComplete,
 	}
 
 	if reflect.DeepEqual(updatedSequence, comparisonSequence) {
@@ -44,11 +48,12 @@ func TestFibRepositoryMap_FindBy(t *testing.T) {
 		Duration: 99,
 		Algo:     "math",
 		Input:    3,
-		Status:   "complete",
+		Status:   StatusComplete,
+		Id:       4,
 	}
 
 	// Set then find sequence.
-	repo.Sequences[4] = testSequence
+	repo.Sequences.Put(testSequence)
 	foundSequence, err := repo.FindBy(4)
 	if err != nil {
 		t.Error("Error was returned while finding sequence: ", err)
@@ -68,16 +73,16 @@ func TestFibRepositoryMap_CalculateFib(t *testing.T) {
 		Duration: -1,
 		Algo:     "math",
 		Input:    13,
-		Status:   "incomplete",
+		Status:   StatusQueued,
 		Id:       -1,
 	}
-	response, err := repo.CalculateFib(sequence, wg)
+	response, err := repo.CalculateFib(context.Background(), sequence, wg)
 	if err != nil {
 		t.Error("Error was returned while calling CalculateFib: ", err)
 		return
 	}
 	if response.Id != 1 {
-		t.Error("Invalid identifier returned from CalculateFib. Want:", 1, "Got:", id)
+		t.Error("Invalid identifier returned from CalculateFib. Want:", 1, "Got:", response.Id)
 	}
 }
 
@@ -89,10 +94,10 @@ func TestFibRepositoryMap_IterateFib(t *testing.T) {
 		Duration: -1,
 		Algo:     "iterate",
 		Input:    65,
-		Status:   "incomplete",
+		Status:   StatusQueued,
 		Id:       -1,
 	}
-	result, err := repo.CalculateFib(sequence, wg)
+	result, err := repo.CalculateFib(context.Background(), sequence, wg)
 	if err != nil {
 		t.Error("Error was returned while calling IterateFib: ", err)
 		return
@@ -113,10 +118,10 @@ func TestFibRepositoryMap_MathFib(t *testing.T) {
 		Duration: -1,
 		Algo:     "math",
 		Input:    65,
-		Status:   "incomplete",
+		Status:   StatusQueued,
 		Id:       -1,
 	}
-	result, err := repo.CalculateFib(sequence, wg)
+	result, err := repo.CalculateFib(context.Background(), sequence, wg)
 	if err != nil {
 		t.Error("Error was returned while calling MathFib: ", err)
 		return
@@ -137,10 +142,10 @@ func TestFibRepositoryMap_RecurseFib(t *testing.T) {
 		Duration: -1,
 		Algo:     "recursive",
 		Input:    65,
-		Status:   "incomplete",
+		Status:   StatusQueued,
 		Id:       -1,
 	}
-	result, err := repo.CalculateFib(sequence, wg)
+	result, err := repo.CalculateFib(context.Background(), sequence, wg)
 	if err != nil {
 		t.Error("Error was returned while calling MathFib: ", err)
 		return
@@ -152,3 +157,388 @@ func TestFibRepositoryMap_RecurseFib(t *testing.T) {
 		t.Error("Invalid result for mathFib calculation. Want:", 10610209857723, "Got:", updatedSequence.Fib)
 	}
 }
+
+func TestRecurse_MatchesDoublingAtInputLimit(t *testing.T) {
+	for _, n := range []uint64{0, 1, 2, 3, 1024, DefaultMaxInput - 1} {
+		if got, want := recurse(context.Background(), n), doubling(context.Background(), n); got.Cmp(want) != 0 {
+			t.Error("Invalid result from recurse. n:", n)
+		}
+	}
+}
+
+// recurseOnCallStack is the previous RecurseFib implementation, one call frame and one memoized number per term. It is
+// kept to compare memory use against recurse.
+func recurseOnCallStack(input uint64, numMap map[uint64]*big.Int) *big.Int {
+	value, keyPresent := numMap[input]
+	if keyPresent {
+		return value
+	}
+	numMap[input] = new(big.Int).Add(recurseOnCallStack(input-1, numMap), recurseOnCallStack(input-2, numMap))
+	return numMap[input]
+}
+
+// Compare B/op of the two benchmarks: the call stack version keeps every term alive, the explicit stack only two.
+func BenchmarkRecurseFib_CallStack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		recurseOnCallStack(20000, map[uint64]*big.Int{0: big.NewInt(0), 1: big.NewInt(1)})
+	}
+}
+
+func BenchmarkRecurseFib_ExplicitStack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		recurse(context.Background(), 20000)
+	}
+}
+
+func TestFibRepositoryMap_DoublingFib(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	sequence := Sequence{
+		Fib:      *big.NewInt(-1),
+		Duration: -1,
+		Algo:     "doubling",
+		Input:    65,
+		Status:   StatusQueued,
+		Id:       -1,
+	}
+	result, err := repo.CalculateFib(context.Background(), sequence, wg)
+	if err != nil {
+		t.Error("Error was returned while calling DoublingFib: ", err)
+		return
+	}
+	//Need to sleep to give go routine time to finish
+	time.Sleep(1 * time.Second)
+	updatedSequence, err := repo.FindBy(result.Id)
+	if updatedSequence.Fib.Cmp(big.NewInt(10610209857723)) != 0 {
+		t.Error("Invalid result for doublingFib calculation. Want:", 10610209857723, "Got:", updatedSequence.Fib)
+	}
+}
+
+func TestDoubling_MatchesIteration(t *testing.T) {
+	a, b := big.NewInt(0), big.NewInt(1)
+	for n := uint64(0); n <= 2000; n++ {
+		if got := doubling(context.Background(), n); got.Cmp(a) != 0 {
+			t.Fatal("Invalid result for doubling. n:", n, "Want:", a, "Got:", got)
+		}
+		a, b = b, a.Add(a, b)
+	}
+}
+
+func TestFibRepositoryMap_MatrixFib(t *testing.T) {
+	for _, mode := range []string{"naive", "parallel"} {
+		wg := &sync.WaitGroup{}
+		repo := NewFibRepository()
+		sequence := Sequence{
+			Fib:      *big.NewInt(-1),
+			Duration: -1,
+			Algo:     "matrix",
+			Input:    65,
+			Status:   StatusQueued,
+			Id:       -1,
+			Multiply: mode,
+		}
+		result, err := repo.CalculateFib(context.Background(), sequence, wg)
+		if err != nil {
+			t.Error("Error was returned while calling MatrixFib: ", err)
+			return
+		}
+		//Need to sleep to give go routine time to finish
+		time.Sleep(1 * time.Second)
+		updatedSequence, err := repo.FindBy(result.Id)
+		if updatedSequence.Fib.Cmp(big.NewInt(10610209857723)) != 0 {
+			t.Error("Invalid result for matrixFib calculation. Mode:", mode, "Want:", 10610209857723, "Got:", updatedSequence.Fib)
+		}
+	}
+}
+
+func TestMatrixPow_MatchesDoubling(t *testing.T) {
+	for n := uint64(0); n <= 500; n++ {
+		want := doubling(context.Background(), n)
+		for mode, multiply := range MatrixMultipliers {
+			if got := matrixPow(context.Background(), n, multiply); got.Cmp(want) != 0 {
+				t.Fatal("Invalid result for matrixPow. Mode:", mode, "n:", n, "Want:", want, "Got:", got)
+			}
+		}
+	}
+}
+
+func TestBinet_ExactUpToInputLimit(t *testing.T) {
+	for _, n := range []uint64{0, 1, 2, 70, 71, 370, 371, 1000, 4321, 99998} {
+		got, exact := binet(context.Background(), n)
+		if !exact {
+			t.Error("Binet's formula reported an inexact result. n:", n)
+		}
+		if want := doubling(context.Background(), n); got.Cmp(want) != 0 {
+			t.Error("Invalid result for binet. n:", n, "Want:", want, "Got:", got)
+		}
+	}
+}
+
+func TestPow(t *testing.T) {
+	base := new(big.Float).SetPrec(128).SetInt64(3)
+	for e, want := range []int64{1, 3, 9, 27, 81, 243} {
+		got, _ := Pow(base, uint64(e)).Int64()
+		if got != want {
+			t.Error("Invalid result for Pow. Exponent:", e, "Want:", want, "Got:", got)
+		}
+	}
+}
+
+func TestFibRepositoryMap_CalculateFibUnknownAlgorithm(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	sequence := Sequence{
+		Fib:      *big.NewInt(-1),
+		Duration: -1,
+		Algo:     "guess",
+		Input:    10,
+		Status:   StatusQueued,
+		Id:       -1,
+	}
+	_, err := repo.CalculateFib(context.Background(), sequence, wg)
+	if err == nil {
+		t.Error("Expected an error for an unregistered algorithm")
+	}
+}
+
+func TestFibRepositoryMap_RegisteredAlgorithm(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Registry.Register(NewAlgorithm("constant", "Always answers 42.", 10, func(context.Context, Sequence) (*big.Int, *errs.AppError) {
+		return big.NewInt(42), nil
+	}))
+	sequence := Sequence{
+		Fib:      *big.NewInt(-1),
+		Duration: -1,
+		Algo:     "constant",
+		Input:    5,
+		Status:   StatusQueued,
+		Id:       -1,
+	}
+	result, err := repo.CalculateFib(context.Background(), sequence, wg)
+	if err != nil {
+		t.Error("Error was returned while calling CalculateFib: ", err)
+		return
+	}
+	//Need to sleep to give go routine time to finish
+	time.Sleep(100 * time.Millisecond)
+	updatedSequence, _ := repo.FindBy(result.Id)
+	if updatedSequence.Fib.Cmp(big.NewInt(42)) != 0 {
+		t.Error("Registered algorithm was not used. Want:", 42, "Got:", updatedSequence.Fib)
+	}
+	found := false
+	for _, algorithm := range repo.Algorithms() {
+		found = found || algorithm.Name() == "constant"
+	}
+	if !found {
+		t.Error("Registered algorithm missing from Algorithms()")
+	}
+}
+
+func TestAlgorithmRegistry_SetMaxInput(t *testing.T) {
+	registry := NewAlgorithmRegistry(DefaultAlgorithms()...)
+	if err := registry.SetMaxInput("doubling", 10000000); err != nil {
+		t.Error("Error was returned while calling SetMaxInput: ", err)
+	}
+	algorithm, _ := registry.Get("doubling")
+	if algorithm.MaxInput() != 10000000 || algorithm.Name() != "doubling" {
+		t.Error("Invalid largest input after SetMaxInput. Want: 10000000 Got:", algorithm.MaxInput())
+	}
+	if answer, _ := algorithm.Compute(context.Background(), Sequence{Input: 11}); answer.Int64() != 55 {
+		t.Error("The limited algorithm should still compute. Want: 55 Got:", answer)
+	}
+	if err := registry.SetMaxInput("unknown", 10); err == nil {
+		t.Error("Expected an error when limiting an unknown algorithm")
+	}
+}
+
+func TestFibRepositoryMap_Cancel(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	started := make(chan bool)
+	repo.Registry.Register(NewAlgorithm("block", "Waits until cancelled.", 10, func(ctx context.Context, _ Sequence) (*big.Int, *errs.AppError) {
+		close(started)
+		<-ctx.Done()
+		return nil, cancelled(ctx)
+	}))
+	sequence := Sequence{
+		Fib:      *big.NewInt(-1),
+		Duration: -1,
+		Algo:     "block",
+		Input:    5,
+		Status:   StatusQueued,
+		Id:       -1,
+	}
+	result, err := repo.CalculateFib(context.Background(), sequence, wg)
+	if err != nil {
+		t.Error("Error was returned while calling CalculateFib: ", err)
+		return
+	}
+	<-started
+	cancelledSequence, err := repo.Cancel(result.Id)
+	if err != nil {
+		t.Error("Error was returned while calling Cancel: ", err)
+		return
+	}
+	if cancelledSequence.Status != StatusCancelled {
+		t.Error("Invalid status returned by Cancel. Want: cancelled Got:", cancelledSequence.Status)
+	}
+	wg.Wait()
+	updatedSequence, _ := repo.FindBy(result.Id)
+	if updatedSequence.Status != StatusCancelled {
+		t.Error("Invalid status after cancelling. Want: cancelled Got:", updatedSequence.Status)
+	}
+	if _, err := repo.Cancel(result.Id); err == nil {
+		t.Error("Expected an error when cancelling a sequence that is no longer running")
+	}
+}
+
+func TestFibRepositoryMap_CancelQueuedLeavesQueue(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Scheduler = NewScheduler(1, 2, 0)
+	release := make(chan bool)
+	repo.Registry.Register(NewAlgorithm("hold", "Waits to be released.", 10, func(ctx context.Context, _ Sequence) (*big.Int, *errs.AppError) {
+		<-release
+		return big.NewInt(2), nil
+	}))
+	repo.CalculateFib(context.Background(), Sequence{Algo: "hold", Input: 3}, wg)
+	first, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 30}, wg)
+	second, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 40}, wg)
+	if status := repo.QueueStatus(second.Id); status.Depth != 2 || status.Position != 2 {
+		t.Error("Invalid queue status before cancelling. Want: depth 2 position 2 Got:", status)
+	}
+	if _, err := repo.Cancel(first.Id); err != nil {
+		t.Fatal("Error was returned while calling Cancel: ", err)
+	}
+	if status := repo.QueueStatus(second.Id); status.Depth != 1 || status.Position != 1 {
+		t.Error("Invalid queue status after cancelling. Want: depth 1 position 1 Got:", status)
+	}
+	if status := repo.QueueStatus(first.Id); status.Position != 0 {
+		t.Error("A cancelled job should not have a queue position. Got:", status.Position)
+	}
+	// The freed slot takes another job instead of answering 429
+	if _, err := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 50}, wg); err != nil {
+		t.Error("Error was returned while queueing after a cancel: ", err)
+	}
+	close(release)
+	wg.Wait()
+	if stored, _ := repo.FindBy(first.Id); stored.Status != StatusCancelled {
+		t.Error("Invalid status after cancelling. Want: cancelled Got:", stored.Status)
+	}
+	if stored, _ := repo.FindBy(second.Id); stored.Status != StatusComplete {
+		t.Error("Invalid status for the remaining job. Want: complete Got:", stored.Status)
+	}
+}
+
+func TestFibRepositoryMap_IdenticalRequestsShareOneComputation(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	var calls int32
+	release := make(chan bool)
+	repo.Registry.Register(NewAlgorithm("gated", "Iterates once released.", 100, func(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return IterateFib(ctx, sequence)
+	}))
+	sequence := Sequence{Algo: "gated", Input: 50, Status: StatusQueued}
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		result, err := repo.CalculateFib(context.Background(), sequence, wg)
+		if err != nil {
+			t.Error("Error was returned while calling CalculateFib: ", err)
+			return
+		}
+		ids = append(ids, result.Id)
+	}
+	close(release)
+	wg.Wait()
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Error("Identical requests were computed more than once. Want: 1 Got:", calls)
+	}
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Error("Identical requests were given the same id:", id)
+		}
+		seen[id] = true
+		stored, _ := repo.FindBy(id)
+		if stored.Status != StatusComplete || stored.Fib.String() != "7778742049" {
+			t.Error("Invalid result for a shared computation. Want: complete 7778742049 Got:", stored.Status, stored.Fib.String())
+		}
+	}
+}
+
+func TestFibRepositoryMap_CancelSharedComputation(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	started := make(chan bool)
+	release := make(chan bool)
+	repo.Registry.Register(NewAlgorithm("gated", "Iterates once released.", 100, func(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+		close(started)
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, cancelled(ctx)
+		}
+		return IterateFib(ctx, sequence)
+	}))
+	sequence := Sequence{Algo: "gated", Input: 10, Status: StatusQueued}
+	leader, _ := repo.CalculateFib(context.Background(), sequence, wg)
+	<-started
+	follower, _ := repo.CalculateFib(context.Background(), sequence, wg)
+	if stored, _ := repo.FindBy(follower.Id); stored.Status != StatusRunning {
+		t.Error("Request joining a running computation should be running. Want: running Got:", stored.Status)
+	}
+	// Cancelling one of the requests leaves the computation running for the other
+	if _, err := repo.Cancel(leader.Id); err != nil {
+		t.Error("Error was returned while calling Cancel: ", err)
+	}
+	close(release)
+	wg.Wait()
+	if stored, _ := repo.FindBy(leader.Id); stored.Status != StatusCancelled {
+		t.Error("Invalid status after cancelling. Want: cancelled Got:", stored.Status)
+	}
+	if stored, _ := repo.FindBy(follower.Id); stored.Status != StatusComplete || stored.Fib.String() != "34" {
+		t.Error("Invalid result for the remaining request. Want: complete 34 Got:", stored.Status, stored.Fib.String())
+	}
+}
+
+func TestAlgorithms_StopWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sequence := Sequence{Input: 99999}
+	for _, algorithm := range DefaultAlgorithms() {
+		if _, err := algorithm.Compute(ctx, sequence); err == nil {
+			t.Error("Expected an error from a cancelled calculation. Algorithm:", algorithm.Name())
+		}
+	}
+}
+
+func TestFibRepositoryMap_NegativeIndices(t *testing.T) {
+	// F(k-2) = F(k) - F(k-1) extends the sequence below 0: F(-1) = 1, F(-2) = -1, F(-3) = 2, F(-4) = -3, ...
+	want := map[int64]*big.Int{0: big.NewInt(0), 1: big.NewInt(1)}
+	for k := int64(-1); k >= -40; k-- {
+		want[k] = new(big.Int).Sub(want[k+2], want[k+1])
+	}
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Cache = NewResultCache(0)
+	for _, algorithm := range repo.Registry.concrete() {
+		for input := -39; input <= 1; input++ {
+			sequence := Sequence{Algo: algorithm.Name(), Input: input}
+			result, err := repo.CalculateFib(context.Background(), sequence, wg)
+			if err != nil {
+				t.Error("Error was returned while calling CalculateFib: ", err)
+				return
+			}
+			wg.Wait()
+			stored, _ := repo.FindBy(result.Id)
+			if stored.Index() != int64(input-1) || stored.Fib.Cmp(want[int64(input-1)]) != 0 {
+				t.Error("Invalid negafibonacci result from", algorithm.Name(), "for input", input, "Want: F(", input-1, ") =", want[int64(input-1)], "Got:", &stored.Fib)
+			}
+		}
+	}
+}
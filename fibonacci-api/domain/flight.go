@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// flight is one computation shared by every sequence that asked for the same number with the same algorithm while it
+// was in progress.
+type flight struct {
+	key string
+	// leader is the sequence that submitted the computation to the scheduler.
+	leader int64
+	// ids are the sequences still waiting for the result. Cancelled sequences are removed.
+	ids     []int64
+	running bool
+	cancel  context.CancelFunc
+}
+
+// flightGroup tracks the computations in progress so identical requests attach to them instead of starting new ones.
+type flightGroup struct {
+	mu    sync.Mutex
+	byKey map[string]*flight
+	byId  map[int64]*flight
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		byKey: make(map[string]*flight),
+		byId:  make(map[int64]*flight),
+	}
+}
+
+// flightKey identifies computations that produce the same result: same kind, parameters, algorithm, options and index.
+func flightKey(sequence Sequence) string {
+	return string(sequence.Kind) + "|" + sequence.parameters() + "|" + sequence.Algo + "|" + sequence.Multiply + "|" +
+		strconv.FormatBool(sequence.Verify) + "|" + strconv.FormatInt(sequence.Index(), 10)
+}
+
+// join attaches the sequence to the computation in progress for its key. If there is none, it registers a new flight
+// led by the sequence and reports leader as true; the caller then has to start the computation. When the sequence
+// joins an existing computation, onAttach is called under the group's lock with whether that computation has started.
+func (group *flightGroup) join(sequence Sequence, cancel context.CancelFunc, onAttach func(running bool)) (f *flight, leader bool) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	key := flightKey(sequence)
+	if existing, ok := group.byKey[key]; ok {
+		existing.ids = append(existing.ids, sequence.Id)
+		group.byId[sequence.Id] = existing
+		onAttach(existing.running)
+		return existing, false
+	}
+	f = &flight{key: key, leader: sequence.Id, ids: []int64{sequence.Id}, cancel: cancel}
+	group.byKey[key] = f
+	group.byId[sequence.Id] = f
+	return f, true
+}
+
+// start marks the flight as running and calls onStart for every attached sequence. It reports false if every
+// sequence was cancelled before the computation started.
+func (group *flightGroup) start(f *flight, onStart func(identifier int64)) bool {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	f.running = true
+	for _, identifier := range f.ids {
+		onStart(identifier)
+	}
+	return len(f.ids) > 0
+}
+
+// finish forgets the flight, releases its context and returns the sequences still waiting for its result.
+func (group *flightGroup) finish(f *flight) []int64 {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	if group.byKey[f.key] == f {
+		delete(group.byKey, f.key)
+	}
+	for _, identifier := range f.ids {
+		delete(group.byId, identifier)
+	}
+	f.cancel()
+	return f.ids
+}
+
+// detach removes a cancelled sequence from its computation. The computation itself is only cancelled once no sequence
+// is waiting for it anymore, in which case detach returns the identifier of the job that computes it and true.
+func (group *flightGroup) detach(identifier int64) (leader int64, abandoned bool) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	f, ok := group.byId[identifier]
+	if !ok {
+		return 0, false
+	}
+	delete(group.byId, identifier)
+	for i, attached := range f.ids {
+		if attached == identifier {
+			f.ids = append(f.ids[:i:i], f.ids[i+1:]...)
+			break
+		}
+	}
+	if len(f.ids) == 0 {
+		// Nobody is left to share a result with, so new requests must not join this dying computation
+		if group.byKey[f.key] == f {
+			delete(group.byKey, f.key)
+		}
+		f.cancel()
+		return f.leader, true
+	}
+	return 0, false
+}
+
+// leaderOf returns the identifier of the sequence whose job computes the result for the given sequence.
+func (group *flightGroup) leaderOf(identifier int64) int64 {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	if f, ok := group.byId[identifier]; ok {
+		return f.leader
+	}
+	return identifier
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/dto"
+	"fibonacci-api/errs"
+	"math/big"
+	"math/bits"
+	"strconv"
+)
+
+// LucasAlgorithm is the name recorded as the algorithm of Lucas sequence jobs.
+const LucasAlgorithm = "lucas"
+
+//LucasParameters are the P and Q of a Lucas sequence job and, once it completes, V_n(P, Q). U_n(P, Q) is stored in
+//the sequence's Fib like every other result.
+type LucasParameters struct {
+	P int64
+	Q int64
+	V big.Int
+}
+
+// key identifies the sequences the parameters define.
+func (params *LucasParameters) key() string {
+	return strconv.FormatInt(params.P, 10) + "_" + strconv.FormatInt(params.Q, 10)
+}
+
+// deepCopy returns a copy of the parameters that shares no big.Int memory with the original.
+func (params *LucasParameters) deepCopy() *LucasParameters {
+	paramsCopy := &LucasParameters{P: params.P, Q: params.Q}
+	paramsCopy.V.Set(&params.V)
+	return paramsCopy
+}
+
+// toLucasResponseDto converts the parameters into the response returned to the client, with V_n once it is known.
+func (params *LucasParameters) toLucasResponseDto(complete bool) *dto.LucasResponse {
+	response := &dto.LucasResponse{P: params.P, Q: params.Q}
+	if complete {
+		v := params.V
+		response.V = &dto.Number{Value: &v, Format: dto.FormatJSONNumber}
+	}
+	return response
+}
+
+// lucasAlgorithm computes the Lucas sequences of a sequence's parameters. It is not registered, since it does not
+// compute fibonacci numbers; CalculateFib picks it for sequences of the lucas kind.
+type lucasAlgorithm struct{}
+
+func (a lucasAlgorithm) Name() string { return LucasAlgorithm }
+
+func (a lucasAlgorithm) Description() string {
+	return "Lucas sequences U_n(P, Q) and V_n(P, Q) by doubling, O(log n) big multiplications."
+}
+
+func (a lucasAlgorithm) MaxInput() int { return DefaultMaxInput }
+
+func (a lucasAlgorithm) Compute(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	u, _, err := a.computeAnnotated(ctx, sequence)
+	return u, err
+}
+
+// computeAnnotated returns U_n and records V_n on the sequence's parameters.
+func (a lucasAlgorithm) computeAnnotated(ctx context.Context, sequence Sequence) (*big.Int, func(*Sequence), *errs.AppError) {
+	if sequence.Lucas == nil {
+		return nil, nil, errs.NewValidationError("Lucas sequences need the parameters P and Q")
+	}
+	u, v := LucasUV(ctx, sequence.index(), sequence.Lucas.P, sequence.Lucas.Q)
+	if err := cancelled(ctx); err != nil {
+		return nil, nil, err
+	}
+	return u, func(sequence *Sequence) {
+		if sequence.Lucas != nil {
+			sequence.Lucas.V.Set(v)
+		}
+	}, nil
+}
+
+// LucasUV returns U_n(P, Q) and V_n(P, Q), the Lucas sequences defined by U_0 = 0, U_1 = 1, V_0 = 2, V_1 = P and
+// X_k = P X_(k-1) - Q X_(k-2). Fibonacci and Lucas numbers are U and V with P = 1, Q = -1. Like doublingPair it walks
+// the bits of n from the most significant down, and it stops early, returning partial values, once ctx is cancelled.
+func LucasUV(ctx context.Context, n uint64, p int64, q int64) (*big.Int, *big.Int) {
+	P := big.NewInt(p)
+	Q := big.NewInt(q)
+	// D = P^2 - 4Q
+	D := new(big.Int).Mul(P, P)
+	D.Sub(D, new(big.Int).Lsh(Q, 2))
+	u := big.NewInt(0)  // U(k)
+	v := big.NewInt(2)  // V(k)
+	qk := big.NewInt(1) // Q^k
+	t1 := new(big.Int)
+	t2 := new(big.Int)
+	for i := bits.Len64(n) - 1; i >= 0 && ctx.Err() == nil; i-- {
+		// U(2k) = U(k)V(k), V(2k) = V(k)^2 - 2Q^k
+		u.Mul(u, v)
+		v.Mul(v, v)
+		v.Sub(v, t1.Lsh(qk, 1))
+		qk.Mul(qk, qk)
+		if n>>uint(i)&1 == 1 {
+			// U(k+1) = (PU(k) + V(k)) / 2, V(k+1) = (DU(k) + PV(k)) / 2. Both numerators are always even.
+			t1.Mul(P, u)
+			t1.Add(t1, v)
+			t2.Mul(D, u)
+			v.Mul(P, v)
+			v.Add(v, t2)
+			u.Rsh(t1, 1)
+			v.Rsh(v, 1)
+			qk.Mul(qk, Q)
+		}
+	}
+	return u, v
+}
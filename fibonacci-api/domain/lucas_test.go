@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestLucasUV_KnownSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		p, q int64
+		u, v []int64
+	}{
+		{"fibonacci and lucas", 1, -1, []int64{0, 1, 1, 2, 3, 5, 8, 13, 21}, []int64{2, 1, 3, 4, 7, 11, 18, 29, 47}},
+		{"pell", 2, -1, []int64{0, 1, 2, 5, 12, 29, 70, 169, 408}, []int64{2, 2, 6, 14, 34, 82, 198, 478, 1154}},
+		{"jacobsthal", 1, -2, []int64{0, 1, 1, 3, 5, 11, 21, 43, 85}, []int64{2, 1, 5, 7, 17, 31, 65, 127, 257}},
+		{"alternating", -1, -1, []int64{0, 1, -1, 2, -3, 5, -8, 13, -21}, []int64{2, -1, 3, -4, 7, -11, 18, -29, 47}},
+		{"mersenne", 3, 2, []int64{0, 1, 3, 7, 15, 31, 63, 127, 255}, []int64{2, 3, 5, 9, 17, 33, 65, 129, 257}},
+	}
+	for _, test := range tests {
+		for n := range test.u {
+			u, v := LucasUV(context.Background(), uint64(n), test.p, test.q)
+			if u.Cmp(big.NewInt(test.u[n])) != 0 || v.Cmp(big.NewInt(test.v[n])) != 0 {
+				t.Error("Invalid", test.name, "terms for n =", n, "Want:", test.u[n], test.v[n], "Got:", u, v)
+			}
+		}
+	}
+}
+
+func TestLucasUV_MatchesDoubling(t *testing.T) {
+	for _, n := range []uint64{100, 1000, 4097} {
+		u, _ := LucasUV(context.Background(), n, 1, -1)
+		if want := doubling(context.Background(), n); u.Cmp(want) != 0 {
+			t.Error("U_n(1, -1) does not match F(n) for n =", n)
+		}
+	}
+}
+
+func TestFibRepositoryMap_LucasSequence(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	pell, err := repo.CalculateFib(context.Background(), Sequence{Kind: KindLucas, Input: 8, Lucas: &LucasParameters{P: 2, Q: -1}}, wg)
+	if err != nil {
+		t.Error("Error was returned while calling CalculateFib: ", err)
+		return
+	}
+	// Same index as a fibonacci request, which must not be answered from or added to the result cache
+	fibonacci, _ := repo.CalculateFib(context.Background(), Sequence{Kind: KindLucas, Input: 8, Lucas: &LucasParameters{P: 1, Q: -1}}, wg)
+	wg.Wait()
+	stored, _ := repo.FindBy(pell.Id)
+	if stored.Status != StatusComplete || stored.Algo != LucasAlgorithm {
+		t.Error("Invalid Lucas sequence job. Want:", StatusComplete, LucasAlgorithm, "Got:", stored.Status, stored.Algo)
+	}
+	if stored.Fib.Cmp(big.NewInt(408)) != 0 || stored.Lucas.V.Cmp(big.NewInt(1154)) != 0 {
+		t.Error("Invalid Pell terms. Want: 408 1154 Got:", &stored.Fib, &stored.Lucas.V)
+	}
+	stored, _ = repo.FindBy(fibonacci.Id)
+	if stored.Fib.Cmp(big.NewInt(21)) != 0 || stored.Lucas.V.Cmp(big.NewInt(47)) != 0 {
+		t.Error("Invalid Fibonacci and Lucas terms. Want: 21 47 Got:", &stored.Fib, &stored.Lucas.V)
+	}
+	if stats := repo.CacheStats(); stats.Entries != 0 {
+		t.Error("Lucas sequences should bypass the result cache. Got entries:", stats.Entries)
+	}
+}
@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// MaxModulus is the largest modulus accepted by FibMod and PisanoPeriod. Pisano periods are at most 6m, so they fit in
+// a uint64 as well.
+const MaxModulus = 1000000000000000000
+
+// mulMod returns a*b mod m using the full 128-bit product.
+func mulMod(a uint64, b uint64, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, m)
+}
+
+// addMod returns a+b mod m for a and b below m.
+func addMod(a uint64, b uint64, m uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 || sum >= m {
+		sum -= m
+	}
+	return sum
+}
+
+// subMod returns a-b mod m for a and b below m.
+func subMod(a uint64, b uint64, m uint64) uint64 {
+	if a >= b {
+		return a - b
+	}
+	return a + (m - b)
+}
+
+// fibPairMod returns (F(n) mod m, F(n+1) mod m) using fast doubling over the bits of n, so n can have any size.
+func fibPairMod(n *big.Int, m uint64) (uint64, uint64) {
+	a, b := uint64(0), uint64(1)%m // F(k), F(k+1)
+	for i := n.BitLen() - 1; i >= 0; i-- {
+		// F(2k) = F(k) * (2F(k+1) - F(k)), F(2k+1) = F(k)^2 + F(k+1)^2
+		c := mulMod(a, subMod(addMod(b, b, m), a, m), m)
+		d := addMod(mulMod(a, a, m), mulMod(b, b, m), m)
+		a, b = c, d
+		if n.Bit(i) == 1 {
+			a, b = b, addMod(a, b, m)
+		}
+	}
+	return a, b
+}
+
+// FibMod returns F(n) mod m for an n of any size and a modulus from 1 to MaxModulus. It takes O(log |n|) word
+// operations. Negative indices use F(-k) = (-1)^(k+1) F(k).
+func FibMod(n *big.Int, m uint64) uint64 {
+	a, _ := fibPairMod(new(big.Int).Abs(n), m)
+	if n.Sign() < 0 && n.Bit(0) == 0 {
+		return subMod(0, a, m)
+	}
+	return a
+}
+
+// isPisanoMultiple reports whether the fibonacci numbers mod m repeat after k terms, that is whether k is a multiple of
+// the Pisano period of m.
+func isPisanoMultiple(k uint64, m uint64) bool {
+	a, b := fibPairMod(new(big.Int).SetUint64(k), m)
+	return a == 0 && b == 1%m
+}
+
+// PisanoPeriod returns the period of the fibonacci numbers mod m for m from 1 to MaxModulus. m is factored and the
+// periods of its prime powers are combined with their least common multiple.
+func PisanoPeriod(m uint64) uint64 {
+	period := uint64(1)
+	for p, e := range factorize(m) {
+		period = lcm(period, primePowerPisanoPeriod(p, e))
+	}
+	return period
+}
+
+// primePowerPisanoPeriod returns the Pisano period of p^e. The period of p divides p-1 when p is 1 or 4 mod 5 and
+// 2(p+1) when p is 2 or 3 mod 5, so it is found by removing prime factors from that bound while the result is still a
+// multiple of the period. The period of p^e is the period of p times a power of p up to p^(e-1).
+func primePowerPisanoPeriod(p uint64, e int) uint64 {
+	var period uint64
+	switch {
+	case p == 2:
+		period = 3
+	case p == 5:
+		period = 20
+	default:
+		if p%5 == 1 || p%5 == 4 {
+			period = p - 1
+		} else {
+			period = 2 * (p + 1)
+		}
+		for q := range factorize(period) {
+			for period%q == 0 && isPisanoMultiple(period/q, p) {
+				period /= q
+			}
+		}
+	}
+	modulus := p
+	for i := 1; i < e; i++ {
+		modulus *= p
+	}
+	for !isPisanoMultiple(period, modulus) {
+		period *= p
+	}
+	return period
+}
+
+// factorize returns the prime factorization of n as a map from each prime to its exponent. Small factors are found by
+// trial division and the rest with Pollard's rho.
+func factorize(n uint64) map[uint64]int {
+	factors := make(map[uint64]int)
+	for p := uint64(2); p < 1000 && p*p <= n; p++ {
+		for n%p == 0 {
+			factors[p]++
+			n /= p
+		}
+	}
+	var split func(n uint64)
+	split = func(n uint64) {
+		if n == 1 {
+			return
+		}
+		if new(big.Int).SetUint64(n).ProbablyPrime(0) {
+			factors[n]++
+			return
+		}
+		d := pollardRho(n)
+		split(d)
+		split(n / d)
+	}
+	split(n)
+	return factors
+}
+
+// pollardRho returns a non-trivial factor of the odd composite n.
+func pollardRho(n uint64) uint64 {
+	for c := uint64(1); ; c++ {
+		step := func(x uint64) uint64 { return addMod(mulMod(x, x, n), c, n) }
+		x, y, d := uint64(2), uint64(2), uint64(1)
+		for d == 1 {
+			x = step(x)
+			y = step(step(y))
+			if x > y {
+				d = gcd(x-y, n)
+			} else {
+				d = gcd(y-x, n)
+			}
+		}
+		if d != n {
+			return d
+		}
+	}
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a uint64, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// lcm returns the least common multiple of a and b.
+func lcm(a uint64, b uint64) uint64 {
+	return a / gcd(a, b) * b
+}
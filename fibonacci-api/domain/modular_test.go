@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func TestFibMod_LargeModuli(t *testing.T) {
+	for _, m := range []uint64{1, 2, 10, 1000000007, 4294967311, MaxModulus} {
+		modulus := new(big.Int).SetUint64(m)
+		for _, n := range []uint64{0, 1, 2, 93, 94, 1000, 4321} {
+			want := new(big.Int).Mod(doubling(context.Background(), n), modulus).Uint64()
+			if got := FibMod(new(big.Int).SetUint64(n), m); got != want {
+				t.Error("Invalid residue from FibMod. n:", n, "m:", m, "Want:", want, "Got:", got)
+			}
+		}
+	}
+}
+
+func TestFibMod_AstronomicalIndex(t *testing.T) {
+	// F(n) mod m repeats with the Pisano period, so adding it to an index of 400 digits must not change the residue
+	n := new(big.Int).Exp(big.NewInt(10), big.NewInt(400), nil)
+	n.Add(n, big.NewInt(12345))
+	for _, m := range []uint64{1000, 1000000007, MaxModulus} {
+		shifted := new(big.Int).Add(n, new(big.Int).SetUint64(PisanoPeriod(m)))
+		if FibMod(n, m) != FibMod(shifted, m) {
+			t.Error("F(n) mod m should repeat after the Pisano period. m:", m)
+		}
+	}
+}
+
+func TestPisanoPeriod_MatchesBruteForce(t *testing.T) {
+	for m := uint64(1); m <= 300; m++ {
+		want := uint64(1)
+		for a, b := uint64(1%m), uint64(1%m); a != 0 || b != 1%m; want++ {
+			a, b = b, (a+b)%m
+		}
+		if got := PisanoPeriod(m); got != want {
+			t.Error("Invalid Pisano period. m:", m, "Want:", want, "Got:", got)
+		}
+	}
+}
+
+func TestPisanoPeriod_KnownValues(t *testing.T) {
+	tests := map[uint64]uint64{
+		1000:       1500,
+		1000000000: 1500000000,
+		1000000007: 2000000016,
+		MaxModulus: 1500000000000000000,
+	}
+	for m, want := range tests {
+		if got := PisanoPeriod(m); got != want {
+			t.Error("Invalid Pisano period. m:", m, "Want:", want, "Got:", got)
+		}
+	}
+}
+
+func TestFactorize(t *testing.T) {
+	tests := map[uint64]map[uint64]int{
+		1:                      {},
+		2:                      {2: 1},
+		360:                    {2: 3, 3: 2, 5: 1},
+		1000000007 * 998244353: {998244353: 1, 1000000007: 1},
+		MaxModulus:             {2: 18, 5: 18},
+	}
+	for n, want := range tests {
+		got := factorize(n)
+		if len(got) != len(want) {
+			t.Error("Invalid factorization of", n, "Want:", want, "Got:", got)
+			continue
+		}
+		for p, e := range want {
+			if got[p] != e {
+				t.Error("Invalid factorization of", n, "Want:", want, "Got:", got)
+			}
+		}
+	}
+}
+
+func TestFibMod_NegativeIndices(t *testing.T) {
+	for k := int64(1); k <= 50; k++ {
+		magnitude := FibMod(big.NewInt(k), 1000)
+		want := magnitude
+		if k%2 == 0 {
+			want = (1000 - magnitude) % 1000
+		}
+		if got := FibMod(big.NewInt(-k), 1000); got != want {
+			t.Error("Invalid residue of F(", -k, ") mod 1000. Want:", want, "Got:", got)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/errs"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AutoAlgorithm is the name of the algorithm that picks the concrete algorithm for each sequence.
+const AutoAlgorithm = "auto"
+
+// costModelMinIndex is the smallest index whose measured duration tunes a cost model. Below it the fixed overhead of
+// a calculation outweighs the part that grows with n.
+const costModelMinIndex = 1000
+
+// costModelSmoothing is the weight of a new measurement in the moving average of a cost model's coefficient.
+const costModelSmoothing = 0.2
+
+//CostModel estimates how long an algorithm takes to calculate F(n) as Coefficient * n^Exponent nanoseconds.
+type CostModel struct {
+	Coefficient float64
+	Exponent    float64
+	// Samples is the number of measured durations the coefficient has been tuned with.
+	Samples int
+}
+
+// Estimate returns the expected duration of calculating F(n).
+func (model CostModel) Estimate(n uint64) time.Duration {
+	return time.Duration(model.Coefficient * math.Pow(float64(n), model.Exponent))
+}
+
+// DefaultCostModels returns cost models for the built-in algorithms, measured on a typical machine. Additions of n-bit
+// numbers make the linear algorithms quadratic, while the logarithmic ones are dominated by their last few Karatsuba
+// multiplications.
+func DefaultCostModels() map[string]CostModel {
+	return map[string]CostModel{
+		"iterate":    {Coefficient: 0.045, Exponent: 2},
+		"recursive":  {Coefficient: 0.015, Exponent: 2},
+		"math":       {Coefficient: 0.18, Exponent: 1.585},
+		"doubling":   {Coefficient: 0.008, Exponent: 1.585},
+		"matrix":     {Coefficient: 0.025, Exponent: 1.585},
+		"checkpoint": {Coefficient: 0.013, Exponent: 1.585},
+	}
+}
+
+//Planner picks the algorithm with the lowest estimated duration for a sequence. Its cost models are tuned with the
+//durations of completed calculations. It is safe for concurrent use.
+type Planner struct {
+	mu     sync.RWMutex
+	models map[string]CostModel
+}
+
+// NewPlanner creates a planner using the given cost models. Algorithms without a model are never picked.
+func NewPlanner(models map[string]CostModel) *Planner {
+	planner := &Planner{models: make(map[string]CostModel)}
+	for name, model := range models {
+		planner.models[name] = model
+	}
+	return planner
+}
+
+// Model returns the current cost model of the algorithm.
+func (planner *Planner) Model(name string) (CostModel, bool) {
+	planner.mu.RLock()
+	defer planner.mu.RUnlock()
+	model, ok := planner.models[name]
+	return model, ok
+}
+
+// Observe tunes the algorithm's cost model with the measured duration of calculating F(n).
+func (planner *Planner) Observe(name string, n uint64, duration time.Duration) {
+	if n < costModelMinIndex {
+		return
+	}
+	planner.mu.Lock()
+	defer planner.mu.Unlock()
+	model, ok := planner.models[name]
+	if !ok {
+		return
+	}
+	measured := float64(duration.Nanoseconds()) / math.Pow(float64(n), model.Exponent)
+	model.Coefficient = (1-costModelSmoothing)*model.Coefficient + costModelSmoothing*measured
+	model.Samples++
+	planner.models[name] = model
+}
+
+// Choose returns the algorithm with the lowest estimated duration for the sequence among those that support its input,
+// together with a human readable reason for the choice.
+func (planner *Planner) Choose(algorithms []Algorithm, sequence Sequence) (Algorithm, string, *errs.AppError) {
+	type candidate struct {
+		algorithm Algorithm
+		estimate  time.Duration
+	}
+	var candidates []candidate
+	var skipped []string
+	planner.mu.RLock()
+	for _, algorithm := range algorithms {
+		model, ok := planner.models[algorithm.Name()]
+		if !ok {
+			continue
+		}
+		if !sequence.supportedBy(algorithm) {
+			skipped = append(skipped, fmt.Sprintf("%s supports indices up to %d", algorithm.Name(), algorithm.MaxInput()))
+			continue
+		}
+		candidates = append(candidates, candidate{algorithm, model.Estimate(sequence.index())})
+	}
+	planner.mu.RUnlock()
+	if len(candidates) == 0 {
+		return nil, "", errs.NewValidationError(fmt.Sprintf("No algorithm supports index %d", sequence.index()))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].estimate < candidates[j].estimate })
+	estimates := make([]string, len(candidates))
+	for i, c := range candidates {
+		estimates[i] = c.algorithm.Name() + " " + c.estimate.String()
+	}
+	reason := "Lowest estimated duration for index " + fmt.Sprint(sequence.index()) + ": " + strings.Join(estimates, ", ")
+	if len(skipped) > 0 {
+		reason += ". Skipped " + strings.Join(skipped, ", ")
+	}
+	return candidates[0].algorithm, reason, nil
+}
+
+// autoAlgorithm lists "auto" among the registered algorithms. CalculateFib resolves it to a concrete algorithm before
+// the sequence is queued; Compute only delegates for callers that run it directly.
+type autoAlgorithm struct {
+	registry *AlgorithmRegistry
+	planner  *Planner
+}
+
+// NewAutoAlgorithm creates the "auto" algorithm, which calculates with the algorithm the planner picks from registry.
+func NewAutoAlgorithm(registry *AlgorithmRegistry, planner *Planner) Algorithm {
+	return autoAlgorithm{registry: registry, planner: planner}
+}
+
+func (a autoAlgorithm) Name() string { return AutoAlgorithm }
+
+func (a autoAlgorithm) Description() string {
+	return "Picks the algorithm with the lowest estimated duration for n, tuned from the durations of past calculations."
+}
+
+// MaxInput is the largest input supported by any algorithm the planner can pick.
+func (a autoAlgorithm) MaxInput() int {
+	maxInput := 0
+	for _, algorithm := range a.registry.concrete() {
+		if _, ok := a.planner.Model(algorithm.Name()); ok && algorithm.MaxInput() > maxInput {
+			maxInput = algorithm.MaxInput()
+		}
+	}
+	return maxInput
+}
+
+func (a autoAlgorithm) Compute(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	algorithm, _, err := a.planner.Choose(a.registry.concrete(), sequence)
+	if err != nil {
+		return nil, err
+	}
+	return algorithm.Compute(ctx, sequence)
+}
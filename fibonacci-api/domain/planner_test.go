@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPlanner_ChoosesLowestEstimate(t *testing.T) {
+	planner := NewPlanner(map[string]CostModel{
+		"iterate":  {Coefficient: 1, Exponent: 2},
+		"doubling": {Coefficient: 1, Exponent: 1},
+	})
+	algorithm, reason, err := planner.Choose(DefaultAlgorithms(), Sequence{Input: 5000})
+	if err != nil {
+		t.Error("Error was returned while calling Choose: ", err)
+		return
+	}
+	if algorithm.Name() != "doubling" {
+		t.Error("Invalid algorithm chosen. Want: doubling Got:", algorithm.Name())
+	}
+	if !strings.HasPrefix(reason, "Lowest estimated duration for index 4999: doubling") {
+		t.Error("Invalid reason for the choice. Got:", reason)
+	}
+}
+
+func TestPlanner_SkipsAlgorithmsBelowInput(t *testing.T) {
+	planner := NewPlanner(map[string]CostModel{
+		"small": {Coefficient: 1, Exponent: 1},
+		"large": {Coefficient: 100, Exponent: 1},
+	})
+	algorithms := []Algorithm{
+		NewAlgorithm("small", "Only small inputs.", 10, IterateFib),
+		NewAlgorithm("large", "Any input.", 1000, IterateFib),
+	}
+	algorithm, reason, _ := planner.Choose(algorithms, Sequence{Input: 50})
+	if algorithm.Name() != "large" {
+		t.Error("Invalid algorithm chosen. Want: large Got:", algorithm.Name())
+	}
+	if !strings.Contains(reason, "Skipped small supports indices up to 10") {
+		t.Error("Reason should mention the skipped algorithm. Got:", reason)
+	}
+	if _, _, err := planner.Choose(algorithms, Sequence{Input: 5000}); err == nil {
+		t.Error("Expected an error when no algorithm supports the input")
+	}
+}
+
+func TestPlanner_ObserveTunesModel(t *testing.T) {
+	planner := NewPlanner(map[string]CostModel{
+		"iterate":  {Coefficient: 1, Exponent: 1},
+		"doubling": {Coefficient: 2, Exponent: 1},
+	})
+	// iterate turns out to be much slower than its model predicts
+	for i := 0; i < 20; i++ {
+		planner.Observe("iterate", 10000, 10000*10*time.Nanosecond)
+	}
+	model, _ := planner.Model("iterate")
+	if model.Samples != 20 || model.Coefficient < 9 {
+		t.Error("Invalid model after observing. Want: 20 samples, coefficient near 10 Got:", model.Samples, model.Coefficient)
+	}
+	algorithm, _, _ := planner.Choose(DefaultAlgorithms(), Sequence{Input: 5000})
+	if algorithm.Name() != "doubling" {
+		t.Error("Invalid algorithm chosen after tuning. Want: doubling Got:", algorithm.Name())
+	}
+	// Small inputs are dominated by overhead and do not tune the model
+	planner.Observe("doubling", 10, time.Second)
+	if model, _ := planner.Model("doubling"); model.Samples != 0 {
+		t.Error("Small inputs should not tune the model. Want: 0 samples Got:", model.Samples)
+	}
+}
+
+func TestFibRepositoryMap_CalculateFibAuto(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	result, err := repo.CalculateFib(context.Background(), Sequence{Algo: AutoAlgorithm, Input: 5000, Status: StatusQueued}, wg)
+	if err != nil {
+		t.Error("Error was returned while calling CalculateFib: ", err)
+		return
+	}
+	wg.Wait()
+	stored, _ := repo.FindBy(result.Id)
+	if stored.Algo == AutoAlgorithm || stored.Algo == "" {
+		t.Error("The concrete algorithm should be recorded. Got:", stored.Algo)
+	}
+	if stored.SelectionReason == "" {
+		t.Error("The reason for the choice should be recorded")
+	}
+	if stored.Status != StatusComplete || stored.Fib.Cmp(doubling(context.Background(), 4999)) != 0 {
+		t.Error("Invalid result for auto. Want: complete Got:", stored.Status)
+	}
+	if model, _ := repo.Planner.Model(stored.Algo); model.Samples != 1 {
+		t.Error("The measured duration should tune the model. Want: 1 sample Got:", model.Samples)
+	}
+}
+
+func TestPlanner_LimitsIndexMagnitude(t *testing.T) {
+	planner := NewPlanner(map[string]CostModel{"small": {Coefficient: 1, Exponent: 1}})
+	algorithms := []Algorithm{NewAlgorithm("small", "Indices up to 10.", 10, IterateFib)}
+	// Input n calculates F(n-1), so inputs 11 and -9 reach indices 10 and -10
+	for _, input := range []int{11, -9} {
+		if _, _, err := planner.Choose(algorithms, Sequence{Input: input}); err != nil {
+			t.Error("Indices up to MaxInput in absolute value should be supported. Input:", input, "Got:", err)
+		}
+	}
+	for _, input := range []int{12, -10} {
+		if _, _, err := planner.Choose(algorithms, Sequence{Input: input}); err == nil || err.Message != "No algorithm supports index 11" {
+			t.Error("Indices past MaxInput in absolute value should be skipped. Input:", input, "Got:", err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package domain
+
+import "time"
+
+//Priority is the scheduling class of a Sequence.
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive"
+	PriorityNormal      Priority = "normal"
+	PriorityBatch       Priority = "batch"
+)
+
+// Priorities lists the priority classes from highest to lowest.
+var Priorities = []Priority{PriorityInteractive, PriorityNormal, PriorityBatch}
+
+//PriorityPolicy decides which priority class the scheduler serves next.
+type PriorityPolicy struct {
+	// Strict always serves the highest non-empty class. Otherwise classes are served by weighted round robin.
+	Strict bool
+	// Weights is the share of dispatches each class gets under weighted round robin. Missing classes get a weight of 1.
+	Weights map[Priority]int
+	// MaxWait guards against starvation: a job that has waited longer than MaxWait is served before any younger job,
+	// whatever its class. Zero disables the guard.
+	MaxWait time.Duration
+}
+
+// DefaultPriorityPolicy serves interactive, normal and batch jobs in a 6:3:1 ratio and promotes jobs that have waited
+// longer than 30 seconds.
+func DefaultPriorityPolicy() PriorityPolicy {
+	return PriorityPolicy{
+		Weights: map[Priority]int{PriorityInteractive: 6, PriorityNormal: 3, PriorityBatch: 1},
+		MaxWait: 30 * time.Second,
+	}
+}
+
+// weight returns the round robin weight of the class.
+func (policy PriorityPolicy) weight(priority Priority) int {
+	if weight, ok := policy.Weights[priority]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// priorityQueue holds one fair queue per priority class and picks between the classes according to its policy.
+type priorityQueue struct {
+	policy  PriorityPolicy
+	classes map[Priority]*fairQueue
+	// credits is the smooth weighted round robin state of each class.
+	credits map[Priority]int
+	size    int
+	// seq numbers the jobs in the order they were queued.
+	seq uint64
+}
+
+func newPriorityQueue(policy PriorityPolicy) *priorityQueue {
+	queue := &priorityQueue{
+		policy:  policy,
+		classes: make(map[Priority]*fairQueue),
+		credits: make(map[Priority]int),
+	}
+	for _, priority := range Priorities {
+		queue.classes[priority] = newFairQueue()
+	}
+	return queue
+}
+
+// push queues the job in its priority class, sharing the class with other clients according to clientWeight.
+func (queue *priorityQueue) push(job scheduledJob, clientWeight int) {
+	if clientWeight < 1 {
+		clientWeight = 1
+	}
+	if _, known := queue.classes[job.priority]; !known {
+		job.priority = PriorityNormal
+	}
+	queue.seq++
+	job.seq = queue.seq
+	queue.classes[job.priority].push(job, clientWeight)
+	queue.size++
+}
+
+// pop removes and returns the job to run next.
+func (queue *priorityQueue) pop(now time.Time) (scheduledJob, bool) {
+	if queue.size == 0 {
+		return scheduledJob{}, false
+	}
+	job, _ := queue.classes[queue.nextClass(now)].pop()
+	queue.size--
+	return job, true
+}
+
+// remove takes the job with the given identifier out of whichever class it is queued in.
+func (queue *priorityQueue) remove(identifier int64) (scheduledJob, bool) {
+	for _, class := range queue.classes {
+		if job, ok := class.remove(identifier); ok {
+			queue.size--
+			return job, true
+		}
+	}
+	return scheduledJob{}, false
+}
+
+// nextClass picks the class whose next job runs next.
+func (queue *priorityQueue) nextClass(now time.Time) Priority {
+	// Starvation guard: the oldest overdue job among the classes' next jobs goes first
+	if queue.policy.MaxWait > 0 {
+		var oldest Priority
+		var oldestTime time.Time
+		for _, priority := range Priorities {
+			head, ok := queue.classes[priority].head()
+			if ok && now.Sub(head.enqueued) > queue.policy.MaxWait && (oldest == "" || head.enqueued.Before(oldestTime)) {
+				oldest, oldestTime = priority, head.enqueued
+			}
+		}
+		if oldest != "" {
+			return oldest
+		}
+	}
+	if queue.policy.Strict {
+		for _, priority := range Priorities {
+			if queue.classes[priority].size > 0 {
+				return priority
+			}
+		}
+	}
+	// Smooth weighted round robin over the non-empty classes
+	var chosen Priority
+	total := 0
+	for _, priority := range Priorities {
+		if queue.classes[priority].size == 0 {
+			continue
+		}
+		weight := queue.policy.weight(priority)
+		queue.credits[priority] += weight
+		total += weight
+		if chosen == "" || queue.credits[priority] > queue.credits[chosen] {
+			chosen = priority
+		}
+	}
+	queue.credits[chosen] -= total
+	return chosen
+}
+
+// clone copies the queue so dispatch order can be simulated without changing it.
+func (queue *priorityQueue) clone() *priorityQueue {
+	cloned := newPriorityQueue(queue.policy)
+	for priority, class := range queue.classes {
+		cloned.classes[priority] = class.clone()
+	}
+	for priority, credit := range queue.credits {
+		cloned.credits[priority] = credit
+	}
+	cloned.size = queue.size
+	cloned.seq = queue.seq
+	return cloned
+}
+
+// position returns the 1-based dispatch position of the job with the given identifier, or 0 if it is not queued.
+func (queue *priorityQueue) position(identifier int64, now time.Time) int {
+	simulated := queue.clone()
+	for position := 1; ; position++ {
+		job, ok := simulated.pop(now)
+		if !ok {
+			return 0
+		}
+		if job.id == identifier {
+			return position
+		}
+	}
+}
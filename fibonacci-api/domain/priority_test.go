@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// dispatchOrder pushes jobs of the given classes in order and returns the classes in the order they are popped.
+func dispatchOrder(policy PriorityPolicy, classes []Priority, enqueued time.Time, now time.Time) []Priority {
+	queue := newPriorityQueue(policy)
+	for i, priority := range classes {
+		queue.push(scheduledJob{id: int64(i + 1), priority: priority, enqueued: enqueued}, 1)
+	}
+	var order []Priority
+	for {
+		job, ok := queue.pop(now)
+		if !ok {
+			return order
+		}
+		order = append(order, job.priority)
+	}
+}
+
+func TestPriorityQueue_Strict(t *testing.T) {
+	now := time.Now()
+	classes := []Priority{PriorityBatch, PriorityNormal, PriorityInteractive, PriorityBatch, PriorityInteractive}
+	got := dispatchOrder(PriorityPolicy{Strict: true}, classes, now, now)
+	want := []Priority{PriorityInteractive, PriorityInteractive, PriorityNormal, PriorityBatch, PriorityBatch}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("Invalid strict dispatch order. Want:", want, "Got:", got)
+	}
+}
+
+func TestPriorityQueue_WeightedServesEveryClass(t *testing.T) {
+	now := time.Now()
+	var classes []Priority
+	for i := 0; i < 10; i++ {
+		classes = append(classes, PriorityInteractive, PriorityNormal, PriorityBatch)
+	}
+	policy := PriorityPolicy{Weights: map[Priority]int{PriorityInteractive: 6, PriorityNormal: 3, PriorityBatch: 1}}
+	got := dispatchOrder(policy, classes, now, now)
+	counts := make(map[Priority]int)
+	for _, priority := range got[:10] {
+		counts[priority]++
+	}
+	if counts[PriorityInteractive] != 6 || counts[PriorityNormal] != 3 || counts[PriorityBatch] != 1 {
+		t.Error("Invalid share of the first 10 dispatches. Want: 6/3/1 Got:", counts)
+	}
+}
+
+func TestPriorityQueue_MaxWaitPreventsStarvation(t *testing.T) {
+	now := time.Now()
+	queue := newPriorityQueue(PriorityPolicy{Strict: true, MaxWait: time.Minute})
+	queue.push(scheduledJob{id: 1, priority: PriorityBatch, enqueued: now.Add(-2 * time.Minute)}, 1)
+	queue.push(scheduledJob{id: 2, priority: PriorityInteractive, enqueued: now}, 1)
+	job, _ := queue.pop(now)
+	if job.id != 1 {
+		t.Error("Overdue batch job should run before a fresh interactive job. Got job:", job.id)
+	}
+}
+
+func TestScheduler_QueuePositionFollowsPriority(t *testing.T) {
+	scheduler := NewScheduler(1, 10, 0)
+	scheduler.SetPriorityPolicy(PriorityPolicy{Strict: true})
+	release := make(chan bool)
+	defer close(release)
+	scheduler.Submit(1, PriorityNormal, "", func() { <-release })
+	scheduler.Submit(2, PriorityBatch, "", func() {})
+	scheduler.Submit(3, PriorityInteractive, "", func() {})
+	if position := scheduler.Status(3).Position; position != 1 {
+		t.Error("Interactive job should be first in the queue. Got position:", position)
+	}
+	if position := scheduler.Status(2).Position; position != 2 {
+		t.Error("Batch job should be second in the queue. Got position:", position)
+	}
+}
@@ -0,0 +1,196 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/dto"
+	"fibonacci-api/errs"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RaceAlgorithm is the name of the algorithm that runs every other algorithm and keeps the first result.
+const RaceAlgorithm = "race"
+
+//RaceOutcome is how one competitor of a race ended. A competitor that returns an error once the race is decided counts
+//as cancelled, and one that returns a result after the winner counts as finished.
+type RaceOutcome string
+
+const (
+	RaceWon       RaceOutcome = "won"
+	RaceFinished  RaceOutcome = "finished"
+	RaceCancelled RaceOutcome = "cancelled"
+	RaceFailed    RaceOutcome = "failed"
+)
+
+//RaceEntry records how long one competitor of a race ran and how it ended.
+type RaceEntry struct {
+	Algo     string
+	Duration int64
+	Outcome  RaceOutcome
+}
+
+//ToRaceEntryResponseDto converts a RaceEntry into the response returned to the client.
+func (entry RaceEntry) ToRaceEntryResponseDto() dto.RaceEntryResponse {
+	return dto.RaceEntryResponse{
+		Algo:     entry.Algo,
+		Duration: entry.Duration,
+		Outcome:  string(entry.Outcome),
+	}
+}
+
+// annotatedAlgorithm is implemented by algorithms that record on the sequence how its result was computed.
+type annotatedAlgorithm interface {
+	// computeAnnotated works like Compute and also returns a function that records the details on a sequence.
+	computeAnnotated(context.Context, Sequence) (*big.Int, func(*Sequence), *errs.AppError)
+}
+
+// raceAlgorithm runs every concrete algorithm that supports the input at the same time and keeps the first result.
+type raceAlgorithm struct {
+	registry  *AlgorithmRegistry
+	planner   *Planner
+	scheduler *Scheduler
+}
+
+// NewRaceAlgorithm creates the "race" algorithm, which races the algorithms in registry against each other. The
+// winner's duration tunes the planner's cost model. The race runs as one of scheduler's jobs and only runs as many
+// competitors at once as the scheduler has idle workers to lend it, plus its own; a nil scheduler runs them all at once.
+func NewRaceAlgorithm(registry *AlgorithmRegistry, planner *Planner, scheduler *Scheduler) Algorithm {
+	return raceAlgorithm{registry: registry, planner: planner, scheduler: scheduler}
+}
+
+func (a raceAlgorithm) Name() string { return RaceAlgorithm }
+
+func (a raceAlgorithm) Description() string {
+	return "Runs every algorithm at once, keeps the first result and cancels the rest. Records each competitor's time."
+}
+
+// MaxInput is the largest input supported by any competitor.
+func (a raceAlgorithm) MaxInput() int {
+	maxInput := 0
+	for _, algorithm := range a.registry.concrete() {
+		if algorithm.MaxInput() > maxInput {
+			maxInput = algorithm.MaxInput()
+		}
+	}
+	return maxInput
+}
+
+func (a raceAlgorithm) Compute(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	answer, _, err := a.computeAnnotated(ctx, sequence)
+	return answer, err
+}
+
+// raceResult is what a competitor reports when it returns.
+type raceResult struct {
+	competitor int
+	answer     *big.Int
+	err        *errs.AppError
+	elapsed    time.Duration
+}
+
+// computeAnnotated starts the competitors and waits for all of them to return, so none keeps running after the race.
+// When the race is short of workers the competitors with the lowest estimated duration start first, and the others
+// start as workers come free; those still waiting when the race is decided count as cancelled. Competitors use naive
+// matrix products so none runs more goroutines than the worker it was given. The recorded details are the winning
+// algorithm, stored as the sequence's Algo, and every competitor's entry.
+func (a raceAlgorithm) computeAnnotated(ctx context.Context, sequence Sequence) (*big.Int, func(*Sequence), *errs.AppError) {
+	competitors := a.competitors(sequence)
+	if len(competitors) == 0 {
+		return nil, nil, errs.NewValidationError("No algorithm supports the input")
+	}
+	workers := len(competitors)
+	if a.scheduler != nil {
+		lent, giveBack := a.scheduler.Borrow(len(competitors) - 1)
+		defer giveBack()
+		workers = 1 + lent
+	}
+	sequence.Multiply = ""
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan raceResult, len(competitors))
+	startTime := time.Now()
+	started := 0
+	startNext := func() {
+		i, algorithm := started, competitors[started]
+		started++
+		go func() {
+			answer, err := algorithm.Compute(raceCtx, sequence)
+			results <- raceResult{competitor: i, answer: answer, err: err, elapsed: time.Since(startTime)}
+		}()
+	}
+	for started < workers {
+		startNext()
+	}
+	entries := make([]RaceEntry, len(competitors))
+	winner := -1
+	var answer *big.Int
+	var failures []string
+	for returned := 0; returned < started; returned++ {
+		result := <-results
+		entry := RaceEntry{Algo: competitors[result.competitor].Name(), Duration: result.elapsed.Microseconds()}
+		switch {
+		case result.err == nil && winner < 0:
+			winner, answer = result.competitor, result.answer
+			entry.Outcome = RaceWon
+			cancel()
+		case result.err == nil:
+			entry.Outcome = RaceFinished
+		case raceCtx.Err() != nil:
+			entry.Outcome = RaceCancelled
+		default:
+			entry.Outcome = RaceFailed
+			failures = append(failures, entry.Algo+": "+result.err.Message)
+		}
+		entries[result.competitor] = entry
+		// The competitor's worker moves on to the next one still waiting
+		if raceCtx.Err() == nil && started < len(competitors) {
+			startNext()
+		}
+	}
+	for i := started; i < len(competitors); i++ {
+		entries[i] = RaceEntry{Algo: competitors[i].Name(), Duration: time.Since(startTime).Microseconds(), Outcome: RaceCancelled}
+	}
+	if winner < 0 {
+		if err := cancelled(ctx); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errs.NewUnexpectedError("Every algorithm failed. " + strings.Join(failures, "; "))
+	}
+	winnerName := competitors[winner].Name()
+	a.planner.Observe(winnerName, sequence.index(), time.Duration(entries[winner].Duration)*time.Microsecond)
+	return answer, func(sequence *Sequence) {
+		sequence.Algo = winnerName
+		sequence.Race = append([]RaceEntry(nil), entries...)
+	}, nil
+}
+
+// competitors returns the concrete algorithms that support the sequence's input, those with the lowest estimated
+// duration first. Algorithms without a cost model follow the others in name order.
+func (a raceAlgorithm) competitors(sequence Sequence) []Algorithm {
+	type competitor struct {
+		algorithm Algorithm
+		estimate  time.Duration
+		modelled  bool
+	}
+	var candidates []competitor
+	for _, algorithm := range a.registry.concrete() {
+		if !sequence.supportedBy(algorithm) {
+			continue
+		}
+		model, modelled := a.planner.Model(algorithm.Name())
+		candidates = append(candidates, competitor{algorithm, model.Estimate(sequence.index()), modelled})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].modelled != candidates[j].modelled {
+			return candidates[i].modelled
+		}
+		return candidates[i].estimate < candidates[j].estimate
+	})
+	competitors := make([]Algorithm, len(candidates))
+	for i, candidate := range candidates {
+		competitors[i] = candidate.algorithm
+	}
+	return competitors
+}
@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/errs"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFibRepositoryMap_CalculateFibRace(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	result, err := repo.CalculateFib(context.Background(), Sequence{Algo: RaceAlgorithm, Input: 3000}, wg)
+	if err != nil {
+		t.Error("Error was returned while calling CalculateFib: ", err)
+		return
+	}
+	wg.Wait()
+	stored, _ := repo.FindBy(result.Id)
+	if stored.Status != StatusComplete || stored.Fib.Cmp(doubling(context.Background(), 2999)) != 0 {
+		t.Error("Invalid result for race. Want: complete Got:", stored.Status)
+	}
+	if len(stored.Race) != len(repo.Registry.concrete()) {
+		t.Error("Every algorithm should compete. Want:", len(repo.Registry.concrete()), "Got:", len(stored.Race))
+	}
+	winners := 0
+	for _, entry := range stored.Race {
+		if entry.Outcome == RaceWon {
+			winners++
+			if entry.Algo != stored.Algo {
+				t.Error("The winner should be recorded as the algorithm. Want:", entry.Algo, "Got:", stored.Algo)
+			}
+		}
+	}
+	if winners != 1 {
+		t.Error("Invalid number of winners. Want: 1 Got:", winners)
+	}
+}
+
+func TestRaceAlgorithm_CancelsLosers(t *testing.T) {
+	slowStopped := make(chan bool, 1)
+	registry := NewAlgorithmRegistry(
+		NewAlgorithm("fast", "Iterates.", 100, IterateFib),
+		NewAlgorithm("slow", "Waits until cancelled.", 100, func(ctx context.Context, _ Sequence) (*big.Int, *errs.AppError) {
+			<-ctx.Done()
+			slowStopped <- true
+			return nil, cancelled(ctx)
+		}),
+		NewAlgorithm("tiny", "Too small to compete.", 5, IterateFib),
+	)
+	race := NewRaceAlgorithm(registry, NewPlanner(nil), nil).(raceAlgorithm)
+	answer, annotate, err := race.computeAnnotated(context.Background(), Sequence{Input: 11})
+	if err != nil {
+		t.Error("Error was returned while racing: ", err)
+		return
+	}
+	if answer.Int64() != 55 {
+		t.Error("Invalid result from race. Want: 55 Got:", answer)
+	}
+	select {
+	case <-slowStopped:
+	default:
+		t.Error("The losing algorithm should have been cancelled before the race returned")
+	}
+	var sequence Sequence
+	annotate(&sequence)
+	want := map[string]RaceOutcome{"fast": RaceWon, "slow": RaceCancelled}
+	if sequence.Algo != "fast" || len(sequence.Race) != len(want) {
+		t.Error("Invalid race record. Want: fast with 2 entries Got:", sequence.Algo, sequence.Race)
+	}
+	for _, entry := range sequence.Race {
+		if want[entry.Algo] != entry.Outcome {
+			t.Error("Invalid outcome. Algorithm:", entry.Algo, "Want:", want[entry.Algo], "Got:", entry.Outcome)
+		}
+	}
+}
+
+func TestRaceAlgorithm_EveryCompetitorFails(t *testing.T) {
+	registry := NewAlgorithmRegistry(NewAlgorithm("broken", "Always fails.", 100, func(context.Context, Sequence) (*big.Int, *errs.AppError) {
+		return nil, errs.NewUnexpectedError("broken")
+	}))
+	race := NewRaceAlgorithm(registry, NewPlanner(nil), nil)
+	if _, err := race.Compute(context.Background(), Sequence{Input: 11}); err == nil || err.Message != "Every algorithm failed. broken: broken" {
+		t.Error("Invalid error when every competitor fails. Got:", err)
+	}
+}
+
+func TestRaceAlgorithm_RunsAtMostIdleWorkers(t *testing.T) {
+	const workers = 2
+	var mu sync.Mutex
+	running, maxRunning, calls := 0, 0, 0
+	track := func(context.Context, Sequence) (*big.Int, *errs.AppError) {
+		mu.Lock()
+		calls++
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, errs.NewUnexpectedError("tracked")
+	}
+	var algorithms []Algorithm
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		algorithms = append(algorithms, NewAlgorithm(name, "Counts running competitors.", 100, track))
+	}
+	scheduler := NewScheduler(workers, 10, 0)
+	race := NewRaceAlgorithm(NewAlgorithmRegistry(algorithms...), NewPlanner(nil), scheduler)
+	var done sync.WaitGroup
+	done.Add(1)
+	scheduler.Submit(1, PriorityNormal, "", func() {
+		defer done.Done()
+		race.Compute(context.Background(), Sequence{Input: 11})
+	})
+	done.Wait()
+	if calls != len(algorithms) {
+		t.Error("Every competitor should run once workers come free. Want:", len(algorithms), "Got:", calls)
+	}
+	if maxRunning > workers {
+		t.Error("Too many competitors ran at once. Want at most:", workers, "Got:", maxRunning)
+	}
+}
+
+func TestFibRepositoryMap_RaceSkipsCache(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 3000}, wg)
+	wg.Wait()
+	result, err := repo.CalculateFib(context.Background(), Sequence{Algo: RaceAlgorithm, Input: 3000}, wg)
+	if err != nil {
+		t.Error("Error was returned while calling CalculateFib: ", err)
+		return
+	}
+	wg.Wait()
+	stored, _ := repo.FindBy(result.Id)
+	if stored.Cached || stored.Algo == RaceAlgorithm || len(stored.Race) == 0 {
+		t.Error("A race should run even when the result is cached. Got cached:", stored.Cached, "algo:", stored.Algo, "entries:", len(stored.Race))
+	}
+}
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fibonacci-api/dto"
+	"fibonacci-api/errs"
+	"math/big"
+	"math/bits"
+	"strings"
+)
+
+// RecurrenceAlgorithm is the name recorded as the algorithm of linear recurrence jobs.
+const RecurrenceAlgorithm = "recurrence"
+
+// DefaultMaxRecurrenceBits is the largest estimated size in bits of the terms linear recurrence jobs may compute. It
+// keeps recurrences with small coefficients, such as the tribonacci numbers, within reach of every index up to
+// DefaultMaxInput.
+const DefaultMaxRecurrenceBits = 1 << 19
+
+//RecurrenceParameters define the linear recurrence a_n = c_1 a_(n-1) + ... + c_k a_(n-k) of a recurrence job, with
+//Coefficients c_1 to c_k and Seeds a_0 to a_(k-1). Both have k entries and are never changed once the job is created.
+type RecurrenceParameters struct {
+	Coefficients []*big.Int
+	Seeds        []*big.Int
+}
+
+// key identifies the recurrence by a hash of its coefficients and seeds, which keeps result file names short.
+func (params *RecurrenceParameters) key() string {
+	checksum := sha256.Sum256([]byte(joinNumbers(params.Coefficients) + ";" + joinNumbers(params.Seeds)))
+	return hex.EncodeToString(checksum[:16])
+}
+
+// deepCopy returns a copy of the parameters that shares no big.Int memory with the original.
+func (params *RecurrenceParameters) deepCopy() *RecurrenceParameters {
+	return &RecurrenceParameters{Coefficients: copyNumbers(params.Coefficients), Seeds: copyNumbers(params.Seeds)}
+}
+
+// toRecurrenceResponseDto converts the parameters into the response returned to the client.
+func (params *RecurrenceParameters) toRecurrenceResponseDto() *dto.RecurrenceResponse {
+	return &dto.RecurrenceResponse{
+		Coefficients: decimalNumbers(params.Coefficients),
+		Seeds:        decimalNumbers(params.Seeds),
+	}
+}
+
+// joinNumbers writes the numbers in decimal, separated by commas.
+func joinNumbers(numbers []*big.Int) string {
+	return strings.Join(decimalNumbers(numbers), ",")
+}
+
+// decimalNumbers writes each number in decimal.
+func decimalNumbers(numbers []*big.Int) []string {
+	decimals := make([]string, len(numbers))
+	for i, number := range numbers {
+		decimals[i] = number.String()
+	}
+	return decimals
+}
+
+// copyNumbers returns copies of the numbers.
+func copyNumbers(numbers []*big.Int) []*big.Int {
+	copies := make([]*big.Int, len(numbers))
+	for i, number := range numbers {
+		copies[i] = new(big.Int).Set(number)
+	}
+	return copies
+}
+
+// recurrenceAlgorithm computes terms of a sequence's linear recurrence. Like lucasAlgorithm it is not registered;
+// CalculateFib picks it for sequences of the recurrence kind.
+type recurrenceAlgorithm struct{}
+
+func (a recurrenceAlgorithm) Name() string { return RecurrenceAlgorithm }
+
+func (a recurrenceAlgorithm) Description() string {
+	return "k-order linear recurrences by companion-matrix exponentiation, O(k^3 log n) big multiplications."
+}
+
+func (a recurrenceAlgorithm) MaxInput() int { return DefaultMaxInput }
+
+func (a recurrenceAlgorithm) Compute(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+	params := sequence.Recurrence
+	if params == nil || len(params.Coefficients) == 0 || len(params.Coefficients) != len(params.Seeds) {
+		return nil, errs.NewValidationError("Recurrences need as many seeds as coefficients")
+	}
+	answer := RecurrenceTerm(ctx, sequence.index(), params.Coefficients, params.Seeds)
+	if err := cancelled(ctx); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// RecurrenceTerm returns a_n of the recurrence a_n = c_1 a_(n-1) + ... + c_k a_(n-k) with the given coefficients c_1
+// to c_k and seeds a_0 to a_(k-1). The state vector s_j = (a_(j+k-1), ..., a_j) advances by s_(j+1) = M s_j, where the
+// companion matrix M holds the coefficients in its first row and ones below the diagonal, so a_n is the first entry of
+// M^(n-k+1) s_0. M is raised to that power by repeated squaring. It stops early, returning a partial value, once ctx is
+// cancelled, checking between the rows of every matrix product.
+func RecurrenceTerm(ctx context.Context, n uint64, coefficients []*big.Int, seeds []*big.Int) *big.Int {
+	k := len(coefficients)
+	if n < uint64(k) {
+		return new(big.Int).Set(seeds[n])
+	}
+	power := identityMatrix(k)
+	e := n - uint64(k) + 1
+	for i := bits.Len64(e) - 1; i >= 0 && ctx.Err() == nil; i-- {
+		power = squareMatrix(ctx, power)
+		if e>>uint(i)&1 == 1 {
+			power = multiplyCompanion(ctx, power, coefficients)
+		}
+	}
+	term := new(big.Int)
+	product := new(big.Int)
+	for j := 0; j < k; j++ {
+		term.Add(term, product.Mul(power[0][j], seeds[k-1-j]))
+	}
+	return term
+}
+
+// zeroMatrix returns a k x k matrix of zeros.
+func zeroMatrix(k int) [][]*big.Int {
+	matrix := make([][]*big.Int, k)
+	for i := range matrix {
+		matrix[i] = make([]*big.Int, k)
+		for j := range matrix[i] {
+			matrix[i][j] = new(big.Int)
+		}
+	}
+	return matrix
+}
+
+// identityMatrix returns the k x k identity matrix.
+func identityMatrix(k int) [][]*big.Int {
+	matrix := zeroMatrix(k)
+	for i := range matrix {
+		matrix[i][i].SetInt64(1)
+	}
+	return matrix
+}
+
+// squareMatrix returns x*x as a new matrix. Each row takes k^2 big multiplications, so ctx is checked before every row
+// and a partial matrix is returned once it is cancelled.
+func squareMatrix(ctx context.Context, x [][]*big.Int) [][]*big.Int {
+	k := len(x)
+	square := zeroMatrix(k)
+	product := new(big.Int)
+	for i := 0; i < k && ctx.Err() == nil; i++ {
+		for j := 0; j < k; j++ {
+			entry := square[i][j]
+			for l := 0; l < k; l++ {
+				entry.Add(entry, product.Mul(x[i][l], x[l][j]))
+			}
+		}
+	}
+	return square
+}
+
+// multiplyCompanion returns x*M as a new matrix, where M is the companion matrix of the coefficients. Column j of M
+// holds c_(j+1) on top and a one in row j+1, so each entry of the product takes one big multiplication instead of k.
+// Like squareMatrix it returns a partial matrix once ctx is cancelled.
+func multiplyCompanion(ctx context.Context, x [][]*big.Int, coefficients []*big.Int) [][]*big.Int {
+	k := len(x)
+	result := zeroMatrix(k)
+	for i := 0; i < k && ctx.Err() == nil; i++ {
+		for j := 0; j < k; j++ {
+			entry := result[i][j].Mul(x[i][0], coefficients[j])
+			if j+1 < k {
+				entry.Add(entry, x[i][j+1])
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func numbers(values ...int64) []*big.Int {
+	result := make([]*big.Int, len(values))
+	for i, value := range values {
+		result[i] = big.NewInt(value)
+	}
+	return result
+}
+
+func TestRecurrenceTerm_KnownSequences(t *testing.T) {
+	tests := []struct {
+		name         string
+		coefficients []*big.Int
+		seeds        []*big.Int
+		terms        []int64
+	}{
+		{"fibonacci", numbers(1, 1), numbers(0, 1), []int64{0, 1, 1, 2, 3, 5, 8, 13, 21, 34, 55}},
+		{"tribonacci", numbers(1, 1, 1), numbers(0, 0, 1), []int64{0, 0, 1, 1, 2, 4, 7, 13, 24, 44, 81}},
+		{"tetranacci", numbers(1, 1, 1, 1), numbers(0, 0, 0, 1), []int64{0, 0, 0, 1, 1, 2, 4, 8, 15, 29, 56}},
+		{"padovan", numbers(0, 1, 1), numbers(1, 1, 1), []int64{1, 1, 1, 2, 2, 3, 4, 5, 7, 9, 12}},
+		{"negative coefficient", numbers(2, -1), numbers(0, 1), []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"first order", numbers(-3), numbers(1), []int64{1, -3, 9, -27, 81, -243, 729, -2187, 6561, -19683, 59049}},
+	}
+	for _, test := range tests {
+		for n, want := range test.terms {
+			got := RecurrenceTerm(context.Background(), uint64(n), test.coefficients, test.seeds)
+			if got.Cmp(big.NewInt(want)) != 0 {
+				t.Error("Invalid", test.name, "term for n =", n, "Want:", want, "Got:", got)
+			}
+		}
+	}
+}
+
+func TestRecurrenceTerm_MatchesDoubling(t *testing.T) {
+	for _, n := range []uint64{100, 1000, 4097} {
+		got := RecurrenceTerm(context.Background(), n, numbers(1, 1), numbers(0, 1))
+		if want := doubling(context.Background(), n); got.Cmp(want) != 0 {
+			t.Error("Fibonacci recurrence does not match F(n) for n =", n)
+		}
+	}
+}
+
+func TestFibRepositoryMap_RecurrenceSequence(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	tribonacci := &RecurrenceParameters{Coefficients: numbers(1, 1, 1), Seeds: numbers(0, 0, 1)}
+	result, err := repo.CalculateFib(context.Background(), Sequence{Kind: KindRecurrence, Input: 10, Recurrence: tribonacci}, wg)
+	if err != nil {
+		t.Error("Error was returned while calling CalculateFib: ", err)
+		return
+	}
+	padovan := &RecurrenceParameters{Coefficients: numbers(0, 1, 1), Seeds: numbers(1, 1, 1)}
+	other, _ := repo.CalculateFib(context.Background(), Sequence{Kind: KindRecurrence, Input: 10, Recurrence: padovan}, wg)
+	wg.Wait()
+	stored, _ := repo.FindBy(result.Id)
+	if stored.Status != StatusComplete || stored.Algo != RecurrenceAlgorithm || stored.Fib.Cmp(big.NewInt(81)) != 0 {
+		t.Error("Invalid tribonacci job. Want:", StatusComplete, RecurrenceAlgorithm, 81, "Got:", stored.Status, stored.Algo, &stored.Fib)
+	}
+	stored, _ = repo.FindBy(other.Id)
+	if stored.Fib.Cmp(big.NewInt(12)) != 0 {
+		t.Error("Recurrences with different coefficients should not share a result. Want: 12 Got:", &stored.Fib)
+	}
+}
+
+func TestSquareMatrix_StopsWhenCancelled(t *testing.T) {
+	// Each row of a product takes k^2 big multiplications, so a cancelled product must not compute any row
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	x := identityMatrix(16)
+	for _, matrix := range [][][]*big.Int{squareMatrix(ctx, x), multiplyCompanion(ctx, x, numbers(1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1))} {
+		for i := range matrix {
+			for j := range matrix[i] {
+				if matrix[i][j].Sign() != 0 {
+					t.Error("A cancelled matrix product should stop before computing its rows. Row:", i)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"container/list"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+// DefaultCacheBytes is the memory budget of the result cache created by NewFibRepository.
+const DefaultCacheBytes = 64 << 20
+
+// cacheEntryOverhead approximates the bookkeeping memory of one cache entry on top of its digits.
+const cacheEntryOverhead = 128
+
+type cacheEntry struct {
+	index uint64
+	value *big.Int
+	size  int64
+}
+
+//CacheStats reports how the result cache is being used.
+type CacheStats struct {
+	Hits     uint64
+	Misses   uint64
+	Entries  int
+	Bytes    int64
+	MaxBytes int64
+}
+
+//ResultCache keeps computed fibonacci numbers keyed by their index, evicting the least recently used ones once the
+//total size of the stored numbers passes its byte budget. It is safe for concurrent use.
+type ResultCache struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	usedBytes int64
+	entries   *list.List
+	byIndex   map[uint64]*list.Element
+	hits      uint64
+	misses    uint64
+}
+
+// NewResultCache creates a cache holding at most maxBytes worth of numbers. A budget of 0 disables caching.
+func NewResultCache(maxBytes int64) *ResultCache {
+	return &ResultCache{
+		maxBytes: maxBytes,
+		entries:  list.New(),
+		byIndex:  make(map[uint64]*list.Element),
+	}
+}
+
+// entrySize estimates the memory held by a cached number.
+func entrySize(value *big.Int) int64 {
+	return int64(len(value.Bits()))*bits.UintSize/8 + cacheEntryOverhead
+}
+
+// Get returns a copy of F(index) if it is cached and marks it as recently used.
+func (cache *ResultCache) Get(index uint64) (*big.Int, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.byIndex[index]
+	if !ok {
+		cache.misses++
+		return nil, false
+	}
+	cache.hits++
+	cache.entries.MoveToFront(element)
+	return new(big.Int).Set(element.Value.(*cacheEntry).value), true
+}
+
+// Add stores a copy of F(index), evicting the least recently used numbers until it fits. Numbers larger than the whole
+// budget are not stored.
+func (cache *ResultCache) Add(index uint64, value *big.Int) {
+	size := entrySize(value)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if size > cache.maxBytes {
+		return
+	}
+	if element, ok := cache.byIndex[index]; ok {
+		cache.entries.MoveToFront(element)
+		return
+	}
+	for cache.usedBytes+size > cache.maxBytes {
+		cache.evictOldest()
+	}
+	entry := &cacheEntry{index: index, value: new(big.Int).Set(value), size: size}
+	cache.byIndex[index] = cache.entries.PushFront(entry)
+	cache.usedBytes += size
+}
+
+// evictOldest removes the least recently used number. The caller must hold cache.mu.
+func (cache *ResultCache) evictOldest() {
+	element := cache.entries.Back()
+	if element == nil {
+		return
+	}
+	entry := cache.entries.Remove(element).(*cacheEntry)
+	delete(cache.byIndex, entry.index)
+	cache.usedBytes -= entry.size
+}
+
+// Stats returns the cache's hit and miss counters and its current size.
+func (cache *ResultCache) Stats() CacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return CacheStats{
+		Hits:     cache.hits,
+		Misses:   cache.misses,
+		Entries:  cache.entries.Len(),
+		Bytes:    cache.usedBytes,
+		MaxBytes: cache.maxBytes,
+	}
+}
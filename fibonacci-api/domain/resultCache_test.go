@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/errs"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestResultCache_EvictsLeastRecentlyUsedByBytes(t *testing.T) {
+	small := big.NewInt(5)
+	cache := NewResultCache(3 * entrySize(small))
+	cache.Add(1, small)
+	cache.Add(2, small)
+	cache.Add(3, small)
+	// Touch 1 so 2 becomes the least recently used
+	cache.Get(1)
+	cache.Add(4, small)
+	if _, ok := cache.Get(2); ok {
+		t.Error("Least recently used entry should have been evicted")
+	}
+	for _, index := range []uint64{1, 3, 4} {
+		if _, ok := cache.Get(index); !ok {
+			t.Error("Entry should still be cached:", index)
+		}
+	}
+	// One large number takes the room of several small ones
+	large := new(big.Int).Lsh(big.NewInt(1), 64*8)
+	cache.Add(5, large)
+	stats := cache.Stats()
+	if stats.Bytes > stats.MaxBytes {
+		t.Error("Cache is over budget. Bytes:", stats.Bytes, "MaxBytes:", stats.MaxBytes)
+	}
+	if stats.Hits != 4 || stats.Misses != 1 {
+		t.Error("Invalid counters. Want: 4 hits 1 miss Got:", stats.Hits, "hits", stats.Misses, "misses")
+	}
+}
+
+func TestResultCache_ReturnsCopies(t *testing.T) {
+	cache := NewResultCache(DefaultCacheBytes)
+	value := big.NewInt(13)
+	cache.Add(7, value)
+	value.SetInt64(0)
+	cached, _ := cache.Get(7)
+	cached.SetInt64(1)
+	if again, _ := cache.Get(7); again.Cmp(big.NewInt(13)) != 0 {
+		t.Error("Cached value was changed through a caller's copy. Got:", again)
+	}
+}
+
+func TestFibRepositoryMap_CachedResult(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	first, err := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 65}, wg)
+	if err != nil {
+		t.Fatal("Error was returned while calling CalculateFib: ", err)
+	}
+	wg.Wait()
+	second, err := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 65}, wg)
+	if err != nil {
+		t.Fatal("Error was returned while calling CalculateFib: ", err)
+	}
+	if first.Cached || !second.Cached || second.Status != StatusComplete || second.Id == first.Id {
+		t.Error("Second request should be a complete cache hit with its own id. Got:", second.Id, second.Status, second.Cached)
+	}
+	found, _ := repo.FindBy(second.Id)
+	if found.Fib.Cmp(big.NewInt(10610209857723)) != 0 {
+		t.Error("Invalid cached result. Want:", 10610209857723, "Got:", &found.Fib)
+	}
+	if stats := repo.CacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Error("Invalid cache counters. Want: 1 hit 1 miss Got:", stats.Hits, "hits", stats.Misses, "misses")
+	}
+}
+
+func TestFibRepositoryMap_CachesOnlyTrustedResults(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	wrong := func(context.Context, Sequence) (*big.Int, *errs.AppError) { return big.NewInt(7), nil }
+	repo.Registry.Register(NewAlgorithm("custom", "Returns 7.", 100, wrong))
+	// Replacing a built-in algorithm makes it custom as well
+	repo.Registry.Register(NewAlgorithm("iterate", "Returns 7.", 100, wrong))
+	repo.CalculateFib(context.Background(), Sequence{Algo: "custom", Input: 20}, wg)
+	repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 30}, wg)
+	repo.CalculateFib(context.Background(), Sequence{Algo: "custom", Input: 40, Verify: true}, wg)
+	wg.Wait()
+	for _, index := range []uint64{19, 29, 39} {
+		if _, hit := repo.Cache.Get(index); hit {
+			t.Error("An unverified result of a custom algorithm should not be cached. Index:", index)
+		}
+	}
+	repo.Registry.Register(NewAlgorithm("honest", "Iterates.", 100, IterateFib))
+	repo.CalculateFib(context.Background(), Sequence{Algo: "honest", Input: 50, Verify: true}, wg)
+	repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 60}, wg)
+	wg.Wait()
+	for _, index := range []uint64{49, 59} {
+		if _, hit := repo.Cache.Get(index); !hit {
+			t.Error("A verified or built-in result should be cached. Index:", index)
+		}
+	}
+}
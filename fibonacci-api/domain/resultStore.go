@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"fibonacci-api/errs"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultResultThresholdBytes is the size above which results are written to disk when a ResultStore is configured.
+const DefaultResultThresholdBytes = 256 << 10
+
+//ResultStore writes large fibonacci numbers to files in a directory so sequences only keep a reference to them. Each
+//number is stored once, as its big-endian magnitude, in a file named after its index. Negative numbers go to files
+//ending in .neg.bin. The text of a number in a given base is written next to it the first time it is asked for, so
+//downloads stream from disk instead of converting the number again. It is safe for concurrent use.
+type ResultStore struct {
+	dir       string
+	threshold int64
+}
+
+// NewResultStore creates a store writing numbers larger than threshold bytes into dir, creating dir if needed.
+func NewResultStore(dir string, threshold int64) (*ResultStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &ResultStore{dir: dir, threshold: threshold}, nil
+}
+
+// ShouldStore reports whether the number is large enough to be kept on disk.
+func (store *ResultStore) ShouldStore(value *big.Int) bool {
+	return store != nil && int64((value.BitLen()+7)/8) > store.threshold
+}
+
+// negativeSuffix ends the names of files holding negative numbers, since only the magnitude is written.
+const negativeSuffix = ".neg.bin"
+
+// resultFileName names the file holding the sequence's result: fib-<index>.bin for fibonacci numbers and
+// <kind>-<parameters>-<index>.bin for the other kinds.
+func resultFileName(sequence Sequence) string {
+	index := strconv.FormatUint(sequence.index(), 10)
+	if sequence.isFibonacci() {
+		return "fib-" + index + ".bin"
+	}
+	return string(sequence.Kind) + "-" + sequence.parameters() + "-" + index + ".bin"
+}
+
+// Save writes the number to the named file unless it is already there and returns the file name.
+func (store *ResultStore) Save(name string, value *big.Int) (string, *errs.AppError) {
+	if value.Sign() < 0 {
+		name = strings.TrimSuffix(name, ".bin") + negativeSuffix
+	}
+	if err := store.write(name, func(file io.Writer) error {
+		_, err := file.Write(value.Bytes())
+		return err
+	}); err != nil {
+		return "", errs.NewUnexpectedError("Could not store result: " + err.Error())
+	}
+	return name, nil
+}
+
+// write creates the named file with the contents written by fill unless it is already there. The file is written under
+// a temporary name and renamed, so readers never see a partial file.
+func (store *ResultStore) write(name string, fill func(io.Writer) error) error {
+	path := filepath.Join(store.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	temp, err := os.CreateTemp(store.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = fill(temp)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// textFileName names the file holding the text of the number stored in the named file, written in the given base.
+func textFileName(name string, base int) string {
+	return strings.TrimSuffix(name, ".bin") + ".b" + strconv.Itoa(base) + ".txt"
+}
+
+// Text opens the text of the number stored in the named file, written in the given base. The text is converted and
+// written to disk the first time it is asked for. The caller must close the file.
+func (store *ResultStore) Text(name string, base int) (*os.File, *errs.AppError) {
+	path := filepath.Join(store.dir, textFileName(filepath.Base(name), base))
+	if file, err := os.Open(path); err == nil {
+		return file, nil
+	}
+	value, appError := store.Load(name)
+	if appError != nil {
+		return nil, appError
+	}
+	if err := store.write(filepath.Base(path), func(file io.Writer) error {
+		_, err := io.WriteString(file, value.Text(base))
+		return err
+	}); err != nil {
+		return nil, errs.NewUnexpectedError("Could not store result text: " + err.Error())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errs.NewUnexpectedError("Could not read stored result text: " + err.Error())
+	}
+	return file, nil
+}
+
+// Load reads the number stored in the named file.
+func (store *ResultStore) Load(name string) (*big.Int, *errs.AppError) {
+	data, err := os.ReadFile(filepath.Join(store.dir, filepath.Base(name)))
+	if err != nil {
+		return nil, errs.NewUnexpectedError("Could not read stored result: " + err.Error())
+	}
+	value := new(big.Int).SetBytes(data)
+	if strings.HasSuffix(name, negativeSuffix) {
+		value.Neg(value)
+	}
+	return value, nil
+}
@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fibonacci-api/errs"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestResultStore_SaveAndLoad(t *testing.T) {
+	store, err := NewResultStore(filepath.Join(t.TempDir(), "results"), 16)
+	if err != nil {
+		t.Error("Error was returned while calling NewResultStore: ", err)
+		return
+	}
+	value := doubling(context.Background(), 1000)
+	name, appError := store.Save(resultFileName(Sequence{Input: 1000}), value)
+	if appError != nil {
+		t.Error("Error was returned while calling Save: ", appError)
+		return
+	}
+	if name != "fib-999.bin" {
+		t.Error("Invalid result file name. Want: fib-999.bin Got:", name)
+	}
+	loaded, appError := store.Load(name)
+	if appError != nil || loaded.Cmp(value) != 0 {
+		t.Error("Loaded result does not match the saved one. Error:", appError)
+	}
+	if _, err := os.Stat(filepath.Join(store.dir, name)); err != nil {
+		t.Error("Result file was not written: ", err)
+	}
+}
+
+func TestResultStore_ShouldStore(t *testing.T) {
+	store := &ResultStore{threshold: 16}
+	if store.ShouldStore(big.NewInt(1 << 40)) {
+		t.Error("Numbers below the threshold should stay in memory")
+	}
+	if !store.ShouldStore(new(big.Int).Lsh(big.NewInt(1), 200)) {
+		t.Error("Numbers above the threshold should be stored on disk")
+	}
+	var disabled *ResultStore
+	if disabled.ShouldStore(new(big.Int).Lsh(big.NewInt(1), 200)) {
+		t.Error("A nil store should keep every number in memory")
+	}
+}
+
+func TestFibRepositoryMap_LargeResultsGoToDisk(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Results, _ = NewResultStore(t.TempDir(), 64)
+	small, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 50}, wg)
+	large, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 5000}, wg)
+	wg.Wait()
+	stored, _ := repo.FindBy(small.Id)
+	if stored.ResultFile != "" || stored.Fib.Cmp(big.NewInt(7778742049)) != 0 {
+		t.Error("Small results should stay in memory. Got file:", stored.ResultFile)
+	}
+	stored, _ = repo.FindBy(large.Id)
+	if stored.ResultFile == "" || stored.Fib.Sign() != 0 {
+		t.Error("Large results should only keep a file reference. Got file:", stored.ResultFile)
+	}
+	want := doubling(context.Background(), 4999)
+	if stored.ResultBits != want.BitLen() {
+		t.Error("Invalid result size. Want:", want.BitLen(), "Got:", stored.ResultBits)
+	}
+	if loaded, _ := repo.Results.Load(stored.ResultFile); loaded.Cmp(want) != 0 {
+		t.Error("Stored result does not match F(4999)")
+	}
+	if response := stored.ToNewResponseDto(); response.Fib != nil || response.ResultFile != stored.ResultFile {
+		t.Error("The response should carry the file reference instead of the number")
+	}
+	// Cache hits for large numbers reference the same file
+	cached, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 5000}, wg)
+	if !cached.Cached || cached.ResultFile != stored.ResultFile {
+		t.Error("Cached large results should reference the stored file. Got:", cached.Cached, cached.ResultFile)
+	}
+}
+
+func TestFibRepositoryMap_Result(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Results, _ = NewResultStore(t.TempDir(), 64)
+	started := make(chan bool)
+	repo.Registry.Register(NewAlgorithm("block", "Waits until cancelled.", 10, func(ctx context.Context, _ Sequence) (*big.Int, *errs.AppError) {
+		close(started)
+		<-ctx.Done()
+		return nil, cancelled(ctx)
+	}))
+	small, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 50}, wg)
+	large, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 5000}, wg)
+	running, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "block", Input: 5}, wg)
+	<-started
+	if _, err := repo.Result(running.Id); err == nil {
+		t.Error("Expected an error for a sequence that is not complete")
+	}
+	repo.Cancel(running.Id)
+	wg.Wait()
+	if answer, _ := repo.Result(small.Id); answer.Cmp(big.NewInt(7778742049)) != 0 {
+		t.Error("Invalid result from memory. Want: 7778742049 Got:", answer)
+	}
+	if answer, _ := repo.Result(large.Id); answer.Cmp(doubling(context.Background(), 4999)) != 0 {
+		t.Error("Invalid result from disk. Want: F(4999)")
+	}
+}
+
+func TestFibRepositoryMap_ResultText(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Results, _ = NewResultStore(t.TempDir(), 64)
+	small, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 50}, wg)
+	// Input -4999 is F(-5000) = -F(5000), whose magnitude is stored on disk
+	large, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: -4999}, wg)
+	wg.Wait()
+	want := doubling(context.Background(), 5000)
+	want.Neg(want)
+	tests := []struct {
+		id   int64
+		base int
+		want string
+	}{
+		{small.Id, 10, "7778742049"},
+		{large.Id, 10, want.String()},
+		{large.Id, 16, want.Text(16)},
+		// The second read of a base comes from the text file written by the first
+		{large.Id, 10, want.String()},
+	}
+	for _, test := range tests {
+		reader, appError := repo.ResultText(test.id, test.base)
+		if appError != nil {
+			t.Error("Error was returned while calling ResultText: ", appError)
+			continue
+		}
+		text, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(text) != test.want {
+			t.Error("Invalid result text. Id:", test.id, "Base:", test.base)
+		}
+	}
+	stored, _ := repo.FindBy(large.Id)
+	if _, err := os.Stat(filepath.Join(repo.Results.dir, textFileName(stored.ResultFile, 10))); err != nil {
+		t.Error("Result text was not written next to the result: ", err)
+	}
+}
+
+func TestFibRepositoryMap_RecordsDigest(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Results, _ = NewResultStore(t.TempDir(), 64)
+	small, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 50}, wg)
+	large, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 5000}, wg)
+	wg.Wait()
+	cached, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 50}, wg)
+	stored, _ := repo.FindBy(small.Id)
+	for _, sequence := range []Sequence{*stored, cached} {
+		checksum := sha256.Sum256([]byte("7778742049"))
+		if sequence.Digits != 10 || sequence.SHA256 != hex.EncodeToString(checksum[:]) {
+			t.Error("Invalid digest of F(49). Cached:", sequence.Cached, "Got:", sequence.Digits, sequence.SHA256)
+		}
+	}
+	stored, _ = repo.FindBy(large.Id)
+	if want := doubling(context.Background(), 4999).String(); stored.Digits != len(want) {
+		t.Error("Invalid number of digits of F(4999). Want:", len(want), "Got:", stored.Digits)
+	}
+	// The decimal text was converted for the digest, so it is already on disk for downloads
+	if _, err := os.Stat(filepath.Join(repo.Results.dir, textFileName(stored.ResultFile, 10))); err != nil {
+		t.Error("Decimal text of the stored result was not written: ", err)
+	}
+}
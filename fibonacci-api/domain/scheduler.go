@@ -0,0 +1,192 @@
+package domain
+
+import (
+	"fibonacci-api/errs"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQueueDepth is how many jobs the default scheduler holds while all workers are busy.
+	DefaultQueueDepth = 100
+	// DefaultRetryAfter is how long clients are told to wait when the default scheduler is full.
+	DefaultRetryAfter = time.Second
+)
+
+type scheduledJob struct {
+	id       int64
+	priority Priority
+	client   string
+	enqueued time.Time
+	run      func()
+	// seq, start and finish are set when the job is queued and order it within its priority class.
+	seq    uint64
+	start  float64
+	finish float64
+}
+
+//Scheduler runs jobs on a bounded number of workers and holds the overflow in a bounded queue, which serves the
+//priority classes according to its PriorityPolicy and shares each class fairly between clients. Workers are only
+//started while there is work, so an idle scheduler holds no goroutines.
+type Scheduler struct {
+	mu            sync.Mutex
+	workers       int
+	queueDepth    int
+	retryAfter    time.Duration
+	queue         *priorityQueue
+	clientWeights map[string]int
+	running       int
+	closed        bool
+}
+
+//QueueStatus describes the scheduler's load and where a job sits in the queue. Position is 1-based and 0 when the job
+//is not queued.
+type QueueStatus struct {
+	Position int
+	Depth    int
+	Running  int
+}
+
+// NewScheduler creates a scheduler running at most workers jobs at once and queueing at most queueDepth more.
+// retryAfter is passed on to clients whose jobs are rejected.
+func NewScheduler(workers int, queueDepth int, retryAfter time.Duration) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+	return &Scheduler{
+		workers:    workers,
+		queueDepth: queueDepth,
+		retryAfter: retryAfter,
+		queue:      newPriorityQueue(DefaultPriorityPolicy()),
+	}
+}
+
+// NewDefaultScheduler creates a scheduler with one worker per CPU.
+func NewDefaultScheduler() *Scheduler {
+	return NewScheduler(runtime.NumCPU(), DefaultQueueDepth, DefaultRetryAfter)
+}
+
+// SetPriorityPolicy changes how the scheduler picks between priority classes.
+func (scheduler *Scheduler) SetPriorityPolicy(policy PriorityPolicy) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.queue.policy = policy
+}
+
+// SetClientWeights sets the fair share weight of each client identity. Clients without an entry get a weight of 1.
+func (scheduler *Scheduler) SetClientWeights(weights map[string]int) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.clientWeights = weights
+}
+
+// Submit runs the job on a free worker or queues it in its priority class, behind earlier jobs of the same client. It
+// returns a 429 error when the queue is full and a 503 error once the scheduler has been closed.
+func (scheduler *Scheduler) Submit(identifier int64, priority Priority, client string, run func()) *errs.AppError {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if scheduler.closed {
+		return errs.NewUnavailableError("Server is shutting down and not accepting new jobs", scheduler.retryAfterSeconds())
+	}
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	job := scheduledJob{id: identifier, priority: priority, client: client, enqueued: time.Now(), run: run}
+	if scheduler.running < scheduler.workers {
+		scheduler.running++
+		go scheduler.work(job)
+		return nil
+	}
+	if scheduler.queue.size >= scheduler.queueDepth {
+		return errs.NewTooManyRequestsError("Job queue is full, please try again later", scheduler.retryAfterSeconds())
+	}
+	scheduler.queue.push(job, scheduler.clientWeights[client])
+	return nil
+}
+
+// work runs the job and then keeps taking jobs from the queue until it is empty.
+func (scheduler *Scheduler) work(job scheduledJob) {
+	for {
+		job.run()
+		scheduler.mu.Lock()
+		next, ok := scheduler.queue.pop(time.Now())
+		if !ok {
+			scheduler.running--
+			scheduler.mu.Unlock()
+			return
+		}
+		job = next
+		scheduler.mu.Unlock()
+	}
+}
+
+// Borrow lends up to want idle workers to a running job that wants to spread its work over several goroutines. It
+// returns how many it lent, possibly none, and a function that gives them back; a worker given back while jobs are
+// queued goes on to run them.
+func (scheduler *Scheduler) Borrow(want int) (int, func()) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	lent := scheduler.workers - scheduler.running
+	if want < lent {
+		lent = want
+	}
+	if lent < 0 {
+		lent = 0
+	}
+	scheduler.running += lent
+	var once sync.Once
+	return lent, func() {
+		once.Do(func() {
+			scheduler.mu.Lock()
+			defer scheduler.mu.Unlock()
+			for i := 0; i < lent; i++ {
+				if job, ok := scheduler.queue.pop(time.Now()); ok {
+					go scheduler.work(job)
+				} else {
+					scheduler.running--
+				}
+			}
+		})
+	}
+}
+
+// Remove takes a job that is still waiting in the queue out of it, so it no longer counts against the queue depth, and
+// returns the job's run function. The caller decides whether to run it, for example to release resources the job
+// holds. It reports false if the job is not queued, because it already started or was never submitted.
+func (scheduler *Scheduler) Remove(identifier int64) (func(), bool) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	job, ok := scheduler.queue.remove(identifier)
+	if !ok {
+		return nil, false
+	}
+	return job.run, true
+}
+
+// Status returns the scheduler's load and the queue position of the job with the given identifier.
+func (scheduler *Scheduler) Status(identifier int64) QueueStatus {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	return QueueStatus{
+		Position: scheduler.queue.position(identifier, time.Now()),
+		Depth:    scheduler.queue.size,
+		Running:  scheduler.running,
+	}
+}
+
+// Close stops the scheduler from accepting new jobs. Jobs already running or queued still run.
+func (scheduler *Scheduler) Close() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.closed = true
+}
+
+// retryAfterSeconds rounds the retry delay up to whole seconds, as used by the Retry-After header.
+func (scheduler *Scheduler) retryAfterSeconds() int {
+	return int(math.Ceil(scheduler.retryAfter.Seconds()))
+}
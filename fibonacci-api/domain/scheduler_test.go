@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_QueueFullAndClosed(t *testing.T) {
+	scheduler := NewScheduler(1, 2, 3*time.Second)
+	release := make(chan bool)
+	var done sync.WaitGroup
+	block := func() {
+		defer done.Done()
+		<-release
+	}
+	done.Add(3)
+	for i := int64(1); i <= 3; i++ {
+		if err := scheduler.Submit(i, PriorityNormal, "", block); err != nil {
+			t.Fatal("Error was returned while submitting job", i, ":", err)
+		}
+	}
+	status := scheduler.Status(3)
+	if status.Running != 1 || status.Depth != 2 || status.Position != 2 {
+		t.Error("Invalid queue status. Want: running 1 depth 2 position 2 Got:", status)
+	}
+
+	err := scheduler.Submit(4, PriorityNormal, "", block)
+	if err == nil || err.Code != http.StatusTooManyRequests || err.RetryAfter != 3 {
+		t.Error("Expected a 429 error with Retry-After 3 when the queue is full. Got:", err)
+	}
+
+	close(release)
+	done.Wait()
+	scheduler.Close()
+	err = scheduler.Submit(5, PriorityNormal, "", func() {})
+	if err == nil || err.Code != http.StatusServiceUnavailable {
+		t.Error("Expected a 503 error once the scheduler is closed. Got:", err)
+	}
+}
+
+func TestScheduler_RunsAtMostWorkers(t *testing.T) {
+	const workers = 3
+	scheduler := NewScheduler(workers, 100, 0)
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	var done sync.WaitGroup
+	for i := int64(1); i <= 30; i++ {
+		done.Add(1)
+		err := scheduler.Submit(i, PriorityNormal, "", func() {
+			defer done.Done()
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatal("Error was returned while submitting job", i, ":", err)
+		}
+	}
+	done.Wait()
+	if maxRunning > workers {
+		t.Error("Too many jobs ran at once. Want at most:", workers, "Got:", maxRunning)
+	}
+}
+
+func TestScheduler_RemoveQueuedJob(t *testing.T) {
+	scheduler := NewScheduler(1, 5, 0)
+	release := make(chan bool)
+	defer close(release)
+	scheduler.Submit(1, PriorityNormal, "", func() { <-release })
+	for i := int64(2); i <= 4; i++ {
+		scheduler.Submit(i, PriorityNormal, "a", func() {})
+	}
+	if _, ok := scheduler.Remove(1); ok {
+		t.Error("A running job should not be removed from the queue")
+	}
+	if _, ok := scheduler.Remove(3); !ok {
+		t.Fatal("Expected the queued job to be removed")
+	}
+	if status := scheduler.Status(4); status.Depth != 2 || status.Position != 2 {
+		t.Error("Invalid queue status after removing a job. Want: depth 2 position 2 Got:", status)
+	}
+	// The client's later job moved up into the removed job's share, ahead of a new client's second job
+	scheduler.Submit(5, PriorityNormal, "b", func() {})
+	scheduler.Submit(6, PriorityNormal, "b", func() {})
+	if status := scheduler.Status(4); status.Position != 3 {
+		t.Error("Invalid position of the client's remaining job. Want: 3 Got:", status.Position)
+	}
+}
+
+func TestScheduler_BorrowIdleWorkers(t *testing.T) {
+	scheduler := NewScheduler(3, 10, 0)
+	lent, giveBack := scheduler.Borrow(5)
+	if lent != 3 {
+		t.Error("Invalid number of lent workers. Want: 3 Got:", lent)
+	}
+	ran := make(chan bool)
+	scheduler.Submit(1, PriorityNormal, "", func() { close(ran) })
+	if status := scheduler.Status(1); status.Running != 3 || status.Position != 1 {
+		t.Error("A job should wait while every worker is lent. Want: running 3 position 1 Got:", status)
+	}
+	giveBack()
+	giveBack()
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Error("The queued job did not run once the workers were given back")
+	}
+}
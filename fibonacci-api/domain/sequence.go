@@ -2,35 +2,214 @@
 package domain
 
 import (
+	"context"
 	"fibonacci-api/dto"
 	"fibonacci-api/errs"
+	"io"
 	"math/big"
 	"sync"
+	"time"
+)
+
+//Kind is the family of numbers a Sequence computes.
+type Kind string
+
+const (
+	KindFibonacci  Kind = "fibonacci"
+	KindLucas      Kind = "lucas"
+	KindRecurrence Kind = "recurrence"
+	KindRange      Kind = "range"
 )
 
 type Sequence struct {
-	Fib      big.Int
-	Duration int64
-	Algo     string
-	Input    int
-	Status   string
-	Id       int64
+	Kind                 Kind
+	Fib                  big.Int
+	Duration             int64
+	Algo                 string
+	Input                int
+	Status               Status
+	Id                   int64
+	Multiply             string
+	Priority             Priority
+	Client               string
+	Cached               bool
+	SelectionReason      string
+	Verify               bool
+	Verification         Verification
+	VerificationDuration int64
+	Race                 []RaceEntry
+	Lucas                *LucasParameters
+	Recurrence           *RecurrenceParameters
+	Range                *RangeParameters
+	ResultFile           string
+	ResultBits           int
+	Digits               int
+	SHA256               string
+	FailureReason        string
+	CreatedAt            time.Time
+	StartedAt            time.Time
+	FinishedAt           time.Time
+}
+
+// Transition moves the sequence to the next status, recording when it started running or finished. Transitions the
+// lifecycle does not allow are rejected.
+func (sequence *Sequence) Transition(next Status, at time.Time) *errs.AppError {
+	if err := sequence.Status.validateTransition(next); err != nil {
+		return err
+	}
+	sequence.Status = next
+	if next == StatusRunning {
+		sequence.StartedAt = at
+	} else if next.IsTerminal() {
+		sequence.FinishedAt = at
+	}
+	return nil
 }
 
 //ToNewResponseDto takes a Sequence object and converts it into an appropriate response to the client.
 func (sequence Sequence) ToNewResponseDto() dto.NewResponse {
-	return dto.NewResponse{
-		Fib:      sequence.Fib,
-		Duration: sequence.Duration,
-		Algo:     sequence.Algo,
-		Input:    sequence.Input,
-		Status:   sequence.Status,
-		Id:       sequence.Id,
+	response := dto.NewResponse{
+		Kind:            string(sequence.Kind),
+		Duration:        sequence.Duration,
+		Algo:            sequence.Algo,
+		Input:           sequence.Input,
+		Index:           sequence.Index(),
+		Status:          string(sequence.Status),
+		Id:              sequence.Id,
+		Multiply:        sequence.Multiply,
+		Priority:        string(sequence.Priority),
+		Cached:          sequence.Cached,
+		SelectionReason: sequence.SelectionReason,
+		Verification:    string(sequence.Verification),
+		ResultFile:      sequence.ResultFile,
+		ResultBits:      sequence.ResultBits,
+		Digits:          sequence.Digits,
+		SHA256:          sequence.SHA256,
+		FailureReason:   sequence.FailureReason,
+		CreatedAt:       sequence.CreatedAt,
+		StartedAt:       timeOrNil(sequence.StartedAt),
+		FinishedAt:      timeOrNil(sequence.FinishedAt),
+	}
+	if sequence.ResultFile == "" && sequence.Range == nil {
+		fib := sequence.Fib
+		response.Fib = &dto.Number{Value: &fib, Format: dto.FormatJSONNumber}
+	}
+	if sequence.Lucas != nil {
+		response.Lucas = sequence.Lucas.toLucasResponseDto(sequence.Status == StatusComplete)
+	}
+	if sequence.Recurrence != nil {
+		response.Recurrence = sequence.Recurrence.toRecurrenceResponseDto()
+	}
+	if sequence.Range != nil {
+		response.Range = sequence.Range.toRangeResponseDto()
+	}
+	for _, entry := range sequence.Race {
+		response.Race = append(response.Race, entry.ToRaceEntryResponseDto())
+	}
+	if sequence.Verification != "" && sequence.Verification != VerificationPending {
+		response.VerificationDuration = &sequence.VerificationDuration
+	}
+	return response
+}
+
+// deepCopy returns a copy of the sequence that shares no big.Int memory with the original, apart from the terms of a
+// range, which never change once stored.
+func (sequence Sequence) deepCopy() Sequence {
+	sequenceCopy := sequence
+	sequenceCopy.Fib = big.Int{}
+	sequenceCopy.Fib.Set(&sequence.Fib)
+	sequenceCopy.Race = append([]RaceEntry(nil), sequence.Race...)
+	if sequence.Lucas != nil {
+		sequenceCopy.Lucas = sequence.Lucas.deepCopy()
+	}
+	if sequence.Recurrence != nil {
+		sequenceCopy.Recurrence = sequence.Recurrence.deepCopy()
+	}
+	if sequence.Range != nil {
+		sequenceCopy.Range = sequence.Range.deepCopy()
+	}
+	return sequenceCopy
+}
+
+// timeOrNil returns nil for the zero time so unset timestamps are left out of the response.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Index returns the index of the term the sequence calculates. Fibonacci inputs are 1-based positions, so input n
+// calculates F(n-1) and negative inputs reach the negafibonacci numbers, while the other kinds take the index itself as
+// their input.
+func (sequence Sequence) Index() int64 {
+	if !sequence.isFibonacci() {
+		return int64(sequence.Input)
+	}
+	return int64(sequence.Input) - 1
+}
+
+// index returns the absolute value of the sequence's index, which is the index the algorithms calculate.
+func (sequence Sequence) index() uint64 {
+	if index := sequence.Index(); index < 0 {
+		return uint64(-index)
+	}
+	return uint64(sequence.Index())
+}
+
+// supportedBy reports whether the algorithm can calculate the sequence, which is when the absolute value of its index
+// is at most the algorithm's MaxInput. Request validation applies the same limit.
+func (sequence Sequence) supportedBy(algorithm Algorithm) bool {
+	return sequence.index() <= uint64(algorithm.MaxInput())
+}
+
+// signed turns F(k), calculated for the absolute value k of the sequence's index, into the number of the index itself
+// using F(-k) = (-1)^(k+1) F(k). The magnitude is left untouched, since the result cache may share it.
+func (sequence Sequence) signed(magnitude *big.Int) *big.Int {
+	if index := sequence.Index(); sequence.isFibonacci() && index < 0 && index%2 == 0 {
+		return new(big.Int).Neg(magnitude)
+	}
+	return magnitude
+}
+
+// isFibonacci reports whether the sequence computes a fibonacci number. Sequences without a kind do.
+func (sequence Sequence) isFibonacci() bool {
+	return sequence.Kind == "" || sequence.Kind == KindFibonacci
+}
+
+// parameters identifies the parameters of the sequence's kind, so sequences with the same index but different
+// parameters are never mistaken for each other.
+func (sequence Sequence) parameters() string {
+	switch {
+	case sequence.Lucas != nil:
+		return sequence.Lucas.key()
+	case sequence.Recurrence != nil:
+		return sequence.Recurrence.key()
+	case sequence.Range != nil:
+		return sequence.Range.key()
+	}
+	return ""
+}
+
+//ToCacheStatsResponseDto converts the cache counters into the response returned to the client.
+func (stats CacheStats) ToCacheStatsResponseDto() dto.CacheStatsResponse {
+	return dto.CacheStatsResponse{
+		Hits:     stats.Hits,
+		Misses:   stats.Misses,
+		Entries:  stats.Entries,
+		Bytes:    stats.Bytes,
+		MaxBytes: stats.MaxBytes,
 	}
 }
 
 //FibRepository defines the interface for calculating and retrieving Sequence objects.
 type FibRepository interface {
-	CalculateFib(Sequence, *sync.WaitGroup) (Sequence, *errs.AppError)
+	CalculateFib(context.Context, Sequence, *sync.WaitGroup) (Sequence, *errs.AppError)
 	FindBy(int64) (*Sequence, *errs.AppError)
+	Result(int64) (*big.Int, *errs.AppError)
+	ResultText(int64, int) (io.ReadSeekCloser, *errs.AppError)
+	Algorithms() []Algorithm
+	Cancel(int64) (*Sequence, *errs.AppError)
+	QueueStatus(int64) QueueStatus
+	CacheStats() CacheStats
 }
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"fibonacci-api/errs"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultShardCount is the number of shards used by NewFibRepository.
+const DefaultShardCount = 32
+
+type sequenceShard struct {
+	mu        sync.RWMutex
+	sequences map[int64]Sequence
+}
+
+//SequenceStore is a concurrency-safe store of Sequence objects. Sequences are spread over several independently locked
+//shards so algorithm goroutines writing results do not block handlers reading other sequences.
+type SequenceStore struct {
+	shards []*sequenceShard
+	// Define and keep track of the sequence ids
+	lastId int64
+}
+
+// NewSequenceStore creates an empty store with the given number of shards.
+func NewSequenceStore(shardCount int) *SequenceStore {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	store := &SequenceStore{shards: make([]*sequenceShard, shardCount)}
+	for i := range store.shards {
+		store.shards[i] = &sequenceShard{sequences: make(map[int64]Sequence)}
+	}
+	return store
+}
+
+// NextId increments the store's identifier and returns the new value.
+func (store *SequenceStore) NextId() int64 {
+	return atomic.AddInt64(&store.lastId, 1)
+}
+
+func (store *SequenceStore) shard(identifier int64) *sequenceShard {
+	index := identifier % int64(len(store.shards))
+	if index < 0 {
+		index = -index
+	}
+	return store.shards[index]
+}
+
+// Put stores a copy of the sequence under its identifier, replacing any sequence already stored there.
+func (store *SequenceStore) Put(sequence Sequence) {
+	shard := store.shard(sequence.Id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.sequences[sequence.Id] = sequence.deepCopy()
+}
+
+// Get returns a copy of the sequence stored under the identifier. The copy shares no memory with the stored sequence,
+// so callers may modify it freely.
+func (store *SequenceStore) Get(identifier int64) (Sequence, bool) {
+	shard := store.shard(identifier)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	sequence, sequencePresent := shard.sequences[identifier]
+	if !sequencePresent {
+		return Sequence{}, false
+	}
+	return sequence.deepCopy(), true
+}
+
+// Update applies update to the sequence stored under the identifier while holding its shard's lock. The change is only
+// stored if update returns no error.
+func (store *SequenceStore) Update(identifier int64, update func(*Sequence) *errs.AppError) *errs.AppError {
+	shard := store.shard(identifier)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	sequence, sequencePresent := shard.sequences[identifier]
+	if !sequencePresent {
+		return errs.NewValidationError("There is no sequence with the given identifier")
+	}
+	if err := update(&sequence); err != nil {
+		return err
+	}
+	shard.sequences[identifier] = sequence
+	return nil
+}
+
+// Delete removes the sequence stored under the identifier.
+func (store *SequenceStore) Delete(identifier int64) {
+	shard := store.shard(identifier)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	delete(shard.sequences, identifier)
+}
+
+// Len returns the number of stored sequences.
+func (store *SequenceStore) Len() int {
+	total := 0
+	for _, shard := range store.shards {
+		shard.mu.RLock()
+		total += len(shard.sequences)
+		shard.mu.RUnlock()
+	}
+	return total
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestSequenceStore_GetReturnsDeepCopy(t *testing.T) {
+	store := NewSequenceStore(4)
+	store.Put(Sequence{Id: 7, Fib: *big.NewInt(21)})
+	sequence, _ := store.Get(7)
+	sequence.Fib.SetInt64(1000)
+	stored, _ := store.Get(7)
+	if stored.Fib.Cmp(big.NewInt(21)) != 0 {
+		t.Error("Modifying a returned sequence changed the stored one. Want:", 21, "Got:", &stored.Fib)
+	}
+}
+
+func TestFibRepositoryMap_IdsArePerInstance(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		repo := NewFibRepository()
+		result, err := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 10}, wg)
+		if err != nil {
+			t.Fatal("Error was returned while calling CalculateFib: ", err)
+		}
+		if result.Id != 1 {
+			t.Error("Invalid identifier for a new repository. Want:", 1, "Got:", result.Id)
+		}
+	}
+	wg.Wait()
+}
+
+// TestFibRepositoryMap_ConcurrentStress submits jobs from many goroutines while others poll them, then checks that
+// every job got its own identifier and its own correct result. Run with -race to check the locking.
+func TestFibRepositoryMap_ConcurrentStress(t *testing.T) {
+	const submitters = 16
+	const jobsPerSubmitter = 25
+	algorithms := []string{"iterate", "recursive", "math", "doubling", "matrix"}
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Scheduler = NewScheduler(4, submitters*jobsPerSubmitter, 0)
+
+	var mu sync.Mutex
+	inputs := make(map[int64]int)
+	var submit sync.WaitGroup
+	for s := 0; s < submitters; s++ {
+		submit.Add(1)
+		go func(s int) {
+			defer submit.Done()
+			for j := 0; j < jobsPerSubmitter; j++ {
+				input := 2 + (s*jobsPerSubmitter+j)%300
+				sequence := Sequence{Algo: algorithms[j%len(algorithms)], Input: input}
+				result, err := repo.CalculateFib(context.Background(), sequence, wg)
+				if err != nil {
+					t.Error("Error was returned while calling CalculateFib: ", err)
+					return
+				}
+				mu.Lock()
+				inputs[result.Id] = input
+				mu.Unlock()
+				// Read back while algorithms are still writing
+				if _, err := repo.FindBy(result.Id); err != nil {
+					t.Error("Error was returned while calling FindBy: ", err)
+				}
+			}
+		}(s)
+	}
+	submit.Wait()
+	wg.Wait()
+
+	if len(inputs) != submitters*jobsPerSubmitter {
+		t.Fatal("Identifiers were reused. Want:", submitters*jobsPerSubmitter, "unique ids Got:", len(inputs))
+	}
+	for identifier, input := range inputs {
+		sequence, err := repo.FindBy(identifier)
+		if err != nil {
+			t.Fatal("Error was returned while calling FindBy: ", err)
+		}
+		want := doubling(context.Background(), uint64(input-1))
+		if sequence.Status != StatusComplete || sequence.Fib.Cmp(want) != 0 {
+			t.Error("Invalid result for id", identifier, "algo", sequence.Algo, "input", input, "Want:", want, "Got:", &sequence.Fib, sequence.Status)
+		}
+	}
+}
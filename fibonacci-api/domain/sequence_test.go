@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"encoding/json"
+	"fibonacci-api/dto"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSequence_ToNewResponseDtoFormats(t *testing.T) {
+	// F(100) needs 69 bits, more than a JavaScript number holds exactly
+	fib, _ := new(big.Int).SetString("354224848179261915075", 10)
+	sequence := Sequence{Fib: *fib, Status: StatusComplete}
+	tests := map[string]string{
+		dto.FormatJSONNumber: `"fib":354224848179261915075`,
+		dto.FormatDecimal:    `"fib":"354224848179261915075"`,
+		dto.FormatHex:        `"fib":"1333db76a7c594bfc3"`,
+		dto.FormatBase64:     `"fib":"EzPbdqfFlL/D"`,
+	}
+	for format, want := range tests {
+		response := sequence.ToNewResponseDto()
+		response.Fib.Format = format
+		encoded, err := json.Marshal(response)
+		if err != nil {
+			t.Error("Error was returned while encoding the response: ", err)
+			continue
+		}
+		if !strings.Contains(string(encoded), want) {
+			t.Error("Invalid encoding. Format:", format, "Want:", want, "Got:", string(encoded))
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "fibonacci-api/errs"
+
+//Status is the lifecycle state of a Sequence.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusComplete  Status = "complete"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+	StatusTimedOut  Status = "timed_out"
+)
+
+// transitions lists the states each state may move to. States missing from the map are terminal.
+var transitions = map[Status][]Status{
+	StatusQueued:  {StatusRunning, StatusCancelled, StatusFailed},
+	StatusRunning: {StatusComplete, StatusFailed, StatusCancelled, StatusTimedOut},
+}
+
+// IsTerminal reports whether the status is final.
+func (status Status) IsTerminal() bool {
+	_, hasNext := transitions[status]
+	return !hasNext
+}
+
+// CanTransitionTo reports whether moving from status to next is allowed.
+func (status Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[status] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTransition returns an error if moving from status to next is not allowed.
+func (status Status) validateTransition(next Status) *errs.AppError {
+	if !status.CanTransitionTo(next) {
+		return errs.NewValidationError("Sequence cannot move from " + string(status) + " to " + string(next))
+	}
+	return nil
+}
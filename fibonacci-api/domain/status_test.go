@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/errs"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatus_CanTransitionTo(t *testing.T) {
+	allowed := []struct{ from, to Status }{
+		{StatusQueued, StatusRunning},
+		{StatusQueued, StatusCancelled},
+		{StatusQueued, StatusFailed},
+		{StatusRunning, StatusComplete},
+		{StatusRunning, StatusFailed},
+		{StatusRunning, StatusCancelled},
+		{StatusRunning, StatusTimedOut},
+	}
+	for _, transition := range allowed {
+		if !transition.from.CanTransitionTo(transition.to) {
+			t.Error("Transition should be allowed:", transition.from, "->", transition.to)
+		}
+	}
+	rejected := []struct{ from, to Status }{
+		{StatusQueued, StatusComplete},
+		{StatusRunning, StatusQueued},
+		{StatusComplete, StatusRunning},
+		{StatusCancelled, StatusComplete},
+		{StatusFailed, StatusCancelled},
+		{StatusTimedOut, StatusComplete},
+	}
+	for _, transition := range rejected {
+		if transition.from.CanTransitionTo(transition.to) {
+			t.Error("Transition should be rejected:", transition.from, "->", transition.to)
+		}
+	}
+}
+
+func TestSequence_TransitionTimestamps(t *testing.T) {
+	sequence := Sequence{Status: StatusQueued}
+	started := time.Unix(100, 0)
+	finished := time.Unix(200, 0)
+	if err := sequence.Transition(StatusRunning, started); err != nil {
+		t.Fatal("Error was returned while starting sequence: ", err)
+	}
+	if err := sequence.Transition(StatusComplete, finished); err != nil {
+		t.Fatal("Error was returned while completing sequence: ", err)
+	}
+	if !sequence.StartedAt.Equal(started) || !sequence.FinishedAt.Equal(finished) {
+		t.Error("Invalid timestamps recorded. Got started:", sequence.StartedAt, "finished:", sequence.FinishedAt)
+	}
+	if err := sequence.Transition(StatusFailed, finished); err == nil {
+		t.Error("Expected an error when leaving a terminal status")
+	}
+}
+
+func TestFibRepositoryMap_FailedAlgorithmRecordsReason(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Registry.Register(NewAlgorithm("broken", "Always fails.", 10, func(context.Context, Sequence) (*big.Int, *errs.AppError) {
+		return nil, errs.NewUnexpectedError("broken on purpose")
+	}))
+	result, err := repo.CalculateFib(context.Background(), Sequence{Algo: "broken", Input: 3}, wg)
+	if err != nil {
+		t.Fatal("Error was returned while calling CalculateFib: ", err)
+	}
+	wg.Wait()
+	sequence, _ := repo.FindBy(result.Id)
+	if sequence.Status != StatusFailed || sequence.FailureReason != "broken on purpose" {
+		t.Error("Invalid failed sequence. Got status:", sequence.Status, "reason:", sequence.FailureReason)
+	}
+	if sequence.CreatedAt.IsZero() || sequence.StartedAt.IsZero() || sequence.FinishedAt.IsZero() {
+		t.Error("Missing lifecycle timestamps:", sequence.CreatedAt, sequence.StartedAt, sequence.FinishedAt)
+	}
+}
+
+func TestFibRepositoryMap_JobTimeout(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.JobTimeout = 10 * time.Millisecond
+	repo.Registry.Register(NewAlgorithm("block", "Waits until cancelled.", 10, func(ctx context.Context, _ Sequence) (*big.Int, *errs.AppError) {
+		<-ctx.Done()
+		return nil, cancelled(ctx)
+	}))
+	result, err := repo.CalculateFib(context.Background(), Sequence{Algo: "block", Input: 3}, wg)
+	if err != nil {
+		t.Fatal("Error was returned while calling CalculateFib: ", err)
+	}
+	wg.Wait()
+	sequence, _ := repo.FindBy(result.Id)
+	if sequence.Status != StatusTimedOut {
+		t.Error("Invalid status after job timeout. Want:", StatusTimedOut, "Got:", sequence.Status)
+	}
+}
+
+func TestFibRepositoryMap_JobTimeoutStartsWhenRunning(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Scheduler = NewScheduler(1, 10, 0)
+	repo.JobTimeout = 50 * time.Millisecond
+	release := make(chan bool)
+	repo.Registry.Register(NewAlgorithm("hold", "Waits to be released.", 10, func(ctx context.Context, _ Sequence) (*big.Int, *errs.AppError) {
+		<-release
+		return big.NewInt(2), nil
+	}))
+	repo.CalculateFib(context.Background(), Sequence{Algo: "hold", Input: 3}, wg)
+	queued, err := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 30}, wg)
+	if err != nil {
+		t.Fatal("Error was returned while calling CalculateFib: ", err)
+	}
+	// The second job waits in the queue for longer than its timeout
+	time.Sleep(2 * repo.JobTimeout)
+	close(release)
+	wg.Wait()
+	sequence, _ := repo.FindBy(queued.Id)
+	if sequence.Status != StatusComplete {
+		t.Error("Invalid status for a job that waited in the queue. Want:", StatusComplete, "Got:", sequence.Status)
+	}
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"fibonacci-api/errs"
+	"fmt"
+	"math/big"
+)
+
+//Verification is the outcome of independently checking a computed Sequence.
+type Verification string
+
+const (
+	VerificationPending Verification = "pending"
+	VerificationPassed  Verification = "passed"
+	VerificationFailed  Verification = "failed"
+)
+
+// verificationPrimes are the moduli results are checked against. A wrong result matches all of them by chance with a
+// probability of about 2^-155.
+var verificationPrimes = []uint64{2147483647, 2147483629, 2147483587, 2147483579, 2147483563}
+
+// fibMod returns F(n) mod m using fast doubling.
+func fibMod(n uint64, m uint64) uint64 {
+	return FibMod(new(big.Int).SetUint64(n), m)
+}
+
+// VerifyFib checks that answer is F(n) by comparing it with F(n) computed modulo several primes. It is independent of
+// the algorithm that produced answer and costs O(log n) word operations plus one pass over answer's digits per prime.
+func VerifyFib(n uint64, answer *big.Int) *errs.AppError {
+	residue := new(big.Int)
+	for _, prime := range verificationPrimes {
+		modulus := new(big.Int).SetUint64(prime)
+		got := residue.Mod(answer, modulus).Uint64()
+		if want := fibMod(n, prime); got != want {
+			return errs.NewUnexpectedError(fmt.Sprintf("Verification failed: result mod %d is %d, expected %d", prime, got, want))
+		}
+	}
+	return nil
+}
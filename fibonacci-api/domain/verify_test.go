@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"context"
+	"fibonacci-api/errs"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFibMod_MatchesDoubling(t *testing.T) {
+	for _, n := range []uint64{0, 1, 2, 3, 10, 93, 94, 1000, 4321} {
+		modulus := new(big.Int).SetUint64(verificationPrimes[0])
+		want := new(big.Int).Mod(doubling(context.Background(), n), modulus).Uint64()
+		if got := fibMod(n, verificationPrimes[0]); got != want {
+			t.Error("Invalid residue from fibMod. n:", n, "Want:", want, "Got:", got)
+		}
+	}
+}
+
+func TestVerifyFib(t *testing.T) {
+	answer := doubling(context.Background(), 5000)
+	if err := VerifyFib(5000, answer); err != nil {
+		t.Error("Correct result failed verification: ", err.Message)
+	}
+	if err := VerifyFib(5000, new(big.Int).Add(answer, big.NewInt(1))); err == nil {
+		t.Error("Expected an error when verifying a wrong result")
+	}
+}
+
+func TestFibRepositoryMap_CalculateFibVerify(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	repo := NewFibRepository()
+	repo.Registry.Register(NewAlgorithm("off-by-one", "Returns F(n)+1.", 100, func(ctx context.Context, sequence Sequence) (*big.Int, *errs.AppError) {
+		answer, err := IterateFib(ctx, sequence)
+		return answer.Add(answer, big.NewInt(1)), err
+	}))
+	good, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "doubling", Input: 50, Verify: true}, wg)
+	bad, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "off-by-one", Input: 60, Verify: true}, wg)
+	wg.Wait()
+	stored, _ := repo.FindBy(good.Id)
+	if stored.Status != StatusComplete || stored.Verification != VerificationPassed {
+		t.Error("Invalid status for a verified result. Want: complete passed Got:", stored.Status, stored.Verification)
+	}
+	stored, _ = repo.FindBy(bad.Id)
+	if stored.Status != StatusFailed || stored.Verification != VerificationFailed {
+		t.Error("Invalid status for a wrong result. Want: failed failed Got:", stored.Status, stored.Verification)
+	}
+	if !strings.HasPrefix(stored.FailureReason, "Verification failed") {
+		t.Error("Invalid failure reason for a wrong result. Got:", stored.FailureReason)
+	}
+	if _, hit := repo.Cache.Get(59); hit {
+		t.Error("A result that failed verification should not be cached")
+	}
+	// Cached results are verified too
+	cached, _ := repo.CalculateFib(context.Background(), Sequence{Algo: "iterate", Input: 50, Verify: true}, wg)
+	if !cached.Cached || cached.Verification != VerificationPassed {
+		t.Error("Invalid verification of a cached result. Want: cached passed Got:", cached.Cached, cached.Verification)
+	}
+}
@@ -0,0 +1,7 @@
+package dto
+
+type AlgorithmResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MaxInput    int    `json:"max_input"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+//LucasResponse holds the parameters of a Lucas sequence job and, once it completes, V_n. U_n is the response's fib.
+type LucasResponse struct {
+	P int64   `json:"p"`
+	Q int64   `json:"q"`
+	V *Number `json:"v,omitempty"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+type ModResponse struct {
+	N   string `json:"n"`
+	M   uint64 `json:"m"`
+	Fib uint64 `json:"fib"`
+}
@@ -3,33 +3,326 @@ package dto
 
 import (
 	"fibonacci-api/errs"
+	"fmt"
+	"math/big"
+	"math/bits"
 	"strconv"
+	"strings"
 )
 
+// MaxRecurrenceOrder is the largest number of coefficients a linear recurrence may have.
+const MaxRecurrenceOrder = 16
+
+// MaxRecurrenceDigits is the largest number of decimal digits of a recurrence's coefficients and seeds.
+const MaxRecurrenceDigits = 100
+
+// DefaultPageLimit is the number of range terms returned per page unless the request asks for another limit.
+const DefaultPageLimit = 100
+
+// MaxPageLimit is the largest number of range terms returned per page.
+const MaxPageLimit = 1000
+
+// MaxModIndexDigits is the largest number of decimal digits of the index of a modular fibonacci number.
+const MaxModIndexDigits = 10000
+
 type NewRequest struct {
 	Algorithm string
 	Input     int
 	Id        int64
+	Multiply  string
+	Priority  string
+	Client    string
+	Verify    bool
+	Base      int
+	OmitFib   bool
+	Format    string
+	P         int64
+	Q         int64
+	// Coefficients and Seeds define a linear recurrence: a_n = c_1 a_(n-1) + ... + c_k a_(n-k) from a_0 to a_(k-1).
+	Coefficients []*big.Int
+	Seeds        []*big.Int
+	// ModIndex and Modulus ask for F(ModIndex) mod Modulus.
+	ModIndex *big.Int
+	Modulus  uint64
+	// From and To are the first and last index of a range, and Offset and Limit select the page of its terms.
+	From   int
+	To     int
+	Offset int
+	Limit  int
 }
 
-//ValidateInputNum validates and converts the input number that was passed in.
-func (r NewRequest) ValidateInputNum(num string) (int, *errs.AppError) {
+//ValidateInputNum validates and converts the input number that was passed in. Input n calculates F(n-1), so it is
+//accepted when that index is within the algorithm's limit, from -maxInput to maxInput.
+func (r NewRequest) ValidateInputNum(num string, maxInput int) (int, *errs.AppError) {
 	inputNum, err := strconv.Atoi(num)
-	if err != nil || inputNum < 1 || inputNum >= 100000 {
-		appError := errs.NewValidationError("Please provide a valid input number. (Numbers greater than 0 and less than 100000 only)")
+	if err != nil || !withinIndexLimit(inputNum-1, maxInput) {
+		appError := errs.NewValidationError(fmt.Sprintf("Please provide a valid input number. (Numbers from %d to %d only)", 1-maxInput, maxInput+1))
 		return 0, appError
 	}
 	return inputNum, nil
 }
 
-//ValidateAlgo validates the algorithm that was passed in.
-func (r NewRequest) ValidateAlgo() *errs.AppError {
-	if r.Algorithm != "math" && r.Algorithm != "recursive" && r.Algorithm != "iterate" {
-		return errs.NewValidationError("Please provide valid algorithm: math, recursive, iterate. Got: " + r.Algorithm)
+//ValidateFibIndex validates the fibonacci index n that was passed in instead of an input number and converts it into
+//the matching input, n+1. Indices from -maxInput to maxInput are accepted, including 0.
+func (r NewRequest) ValidateFibIndex(num string, maxInput int) (int, *errs.AppError) {
+	index, err := strconv.Atoi(num)
+	if err != nil || !withinIndexLimit(index, maxInput) {
+		appError := errs.NewValidationError(fmt.Sprintf("Please provide a valid n. (Numbers from %d to %d only)", -maxInput, maxInput))
+		return 0, appError
+	}
+	return index + 1, nil
+}
+
+// withinIndexLimit reports whether the fibonacci index is within an algorithm's largest input, which limits the absolute
+// value of the indices it calculates. Inputs, indices and ranges are all checked with it.
+func withinIndexLimit(index int, maxInput int) bool {
+	return index >= -maxInput && index <= maxInput
+}
+
+//ValidateRange validates and converts the first and last index of a range. Both must be indices from -maxInput to
+//maxInput, and the range may hold at most maxRange terms.
+func (r NewRequest) ValidateRange(from string, to string, maxInput int, maxRange int) (int, int, *errs.AppError) {
+	first, err := strconv.Atoi(from)
+	if err != nil || !withinIndexLimit(first, maxInput) {
+		return 0, 0, errs.NewValidationError(fmt.Sprintf("Please provide a valid from. (Numbers from %d to %d only)", -maxInput, maxInput))
+	}
+	last, err := strconv.Atoi(to)
+	if err != nil || !withinIndexLimit(last, maxInput) {
+		return 0, 0, errs.NewValidationError(fmt.Sprintf("Please provide a valid to. (Numbers from %d to %d only)", -maxInput, maxInput))
+	}
+	if last < first || last-first >= maxRange {
+		return 0, 0, errs.NewValidationError(fmt.Sprintf("Please provide a valid range. (to must be at least from, with at most %d terms) Got: %d to %d", maxRange, first, last))
+	}
+	return first, last, nil
+}
+
+// rangeTermOverhead approximates the memory of one range term on top of its digits.
+const rangeTermOverhead = 64
+
+//ValidateRangeSize checks that the terms of the range from and to fit in maxBytes. F(k) has about |k| log2(phi) bits,
+//so the terms are estimated from the sum of the absolute values of their indices, which has a closed form.
+func (r NewRequest) ValidateRangeSize(from int, to int, maxBytes int) *errs.AppError {
+	const log2Phi = 0.6942419136306174
+	estimate := absSum(from, to)*log2Phi/8 + (float64(to)-float64(from)+1)*rangeTermOverhead
+	if estimate > float64(maxBytes) {
+		return errs.NewValidationError(fmt.Sprintf("Please provide a smaller range. (Terms of at most %d bytes in total only) Got an estimated %.0f bytes", maxBytes, estimate))
 	}
 	return nil
 }
 
+// absSum returns the sum of |k| for k from from to to, computed in floating point so huge ranges cannot overflow.
+func absSum(from int, to int) float64 {
+	triangle := func(n int) float64 { return float64(n) * (float64(n) + 1) / 2 }
+	switch {
+	case from >= 0:
+		return triangle(to) - triangle(from-1)
+	case to <= 0:
+		return triangle(-from) - triangle(-to-1)
+	default:
+		return triangle(-from) + triangle(to)
+	}
+}
+
+//ValidatePage validates the offset and limit selecting a page of range terms. Defaults to the first DefaultPageLimit
+//terms when none are given.
+func (r NewRequest) ValidatePage(offset string, limit string) (int, int, *errs.AppError) {
+	first, pageSize := 0, DefaultPageLimit
+	var err error
+	if offset != "" {
+		if first, err = strconv.Atoi(offset); err != nil || first < 0 {
+			return 0, 0, errs.NewValidationError("Please provide a valid offset. (Numbers from 0 only) Got: " + offset)
+		}
+	}
+	if limit != "" {
+		if pageSize, err = strconv.Atoi(limit); err != nil || pageSize < 1 || pageSize > MaxPageLimit {
+			return 0, 0, errs.NewValidationError(fmt.Sprintf("Please provide a valid limit. (Numbers from 1 to %d only) Got: %s", MaxPageLimit, limit))
+		}
+	}
+	return first, pageSize, nil
+}
+
+//ValidateIndex validates and converts the index of a term that was passed in. Unlike fibonacci inputs, indices start
+//at 0.
+func (r NewRequest) ValidateIndex(num string, maxIndex int) (int, *errs.AppError) {
+	index, err := strconv.Atoi(num)
+	if err != nil || index < 0 || index > maxIndex {
+		appError := errs.NewValidationError(fmt.Sprintf("Please provide a valid index. (Numbers from 0 to %d only)", maxIndex))
+		return 0, appError
+	}
+	return index, nil
+}
+
+//ValidateLucasParameter validates and converts the Lucas sequence parameter with the given name, P or Q.
+func (r NewRequest) ValidateLucasParameter(value string, name string) (int64, *errs.AppError) {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errs.NewValidationError("Please provide a valid " + name + ". (64-bit integers only) Got: " + value)
+	}
+	return parsed, nil
+}
+
+//ValidateRecurrence validates and converts the comma separated coefficients and seeds of a linear recurrence. Both
+//need the same number of integers, from 1 to MaxRecurrenceOrder, with at most MaxRecurrenceDigits digits each.
+func (r NewRequest) ValidateRecurrence(coefficients string, seeds string) ([]*big.Int, []*big.Int, *errs.AppError) {
+	parsedCoefficients, appError := parseNumbers(coefficients, "coefficients")
+	if appError != nil {
+		return nil, nil, appError
+	}
+	parsedSeeds, appError := parseNumbers(seeds, "seeds")
+	if appError != nil {
+		return nil, nil, appError
+	}
+	if len(parsedCoefficients) != len(parsedSeeds) {
+		return nil, nil, errs.NewValidationError(fmt.Sprintf("Please provide as many seeds as coefficients. Got: %d coefficients and %d seeds", len(parsedCoefficients), len(parsedSeeds)))
+	}
+	return parsedCoefficients, parsedSeeds, nil
+}
+
+//ValidateRecurrenceSize checks that the nth term of the linear recurrence fits in maxBits. Each step can add up to the
+//bit length of the largest coefficient plus log2(k) bits to the terms, so the nth term is estimated to have about
+//n*(that many bits) more bits than the largest seed. The cost of the matrix powers grows with the size of the terms,
+//so this bounds the job's time and memory rather than its index.
+func (r NewRequest) ValidateRecurrenceSize(n int, coefficients []*big.Int, seeds []*big.Int, maxBits int) *errs.AppError {
+	stepBits, seedBits := 0, 0
+	for _, coefficient := range coefficients {
+		if coefficient.BitLen() > stepBits {
+			stepBits = coefficient.BitLen()
+		}
+	}
+	stepBits += bits.Len(uint(len(coefficients) - 1))
+	for _, seed := range seeds {
+		if seed.BitLen() > seedBits {
+			seedBits = seed.BitLen()
+		}
+	}
+	if estimate := int64(n)*int64(stepBits) + int64(seedBits); estimate > int64(maxBits) {
+		return errs.NewValidationError(fmt.Sprintf("Please provide a smaller n or smaller coefficients. (Terms of at most %d bits only) Got an estimated %d bits", maxBits, estimate))
+	}
+	return nil
+}
+
+// parseNumbers converts a comma separated list of integers within the recurrence limits.
+func parseNumbers(list string, name string) ([]*big.Int, *errs.AppError) {
+	invalid := errs.NewValidationError(fmt.Sprintf("Please provide valid %s. (1 to %d comma separated integers of at most %d digits) Got: %s", name, MaxRecurrenceOrder, MaxRecurrenceDigits, list))
+	values := strings.Split(list, ",")
+	if list == "" || len(values) > MaxRecurrenceOrder {
+		return nil, invalid
+	}
+	numbers := make([]*big.Int, len(values))
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		number, ok := new(big.Int).SetString(value, 10)
+		if !ok || len(strings.TrimLeft(value, "+-")) > MaxRecurrenceDigits {
+			return nil, invalid
+		}
+		numbers[i] = number
+	}
+	return numbers, nil
+}
+
+//ValidateModIndex validates and converts the index of a modular fibonacci number, a decimal integer of up to
+//MaxModIndexDigits digits.
+func (r NewRequest) ValidateModIndex(num string) (*big.Int, *errs.AppError) {
+	index, ok := new(big.Int).SetString(num, 10)
+	if !ok || len(strings.TrimLeft(num, "+-")) > MaxModIndexDigits {
+		return nil, errs.NewValidationError(fmt.Sprintf("Please provide a valid n. (Integers of at most %d digits only)", MaxModIndexDigits))
+	}
+	return index, nil
+}
+
+//ValidateModulus validates and converts the modulus that was passed in against the largest supported modulus.
+func (r NewRequest) ValidateModulus(modulus string, maxModulus uint64) (uint64, *errs.AppError) {
+	parsed, err := strconv.ParseUint(modulus, 10, 64)
+	if err != nil || parsed < 1 || parsed > maxModulus {
+		return 0, errs.NewValidationError(fmt.Sprintf("Please provide a valid m. (Numbers from 1 to %d only) Got: %s", maxModulus, modulus))
+	}
+	return parsed, nil
+}
+
+//ValidateAlgo validates the algorithm that was passed in against the available algorithms and returns the match.
+func (r NewRequest) ValidateAlgo(algorithms []AlgorithmResponse) (AlgorithmResponse, *errs.AppError) {
+	names := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		if algorithm.Name == r.Algorithm {
+			return algorithm, nil
+		}
+		names[i] = algorithm.Name
+	}
+	return AlgorithmResponse{}, errs.NewValidationError("Please provide valid algorithm: " + strings.Join(names, ", ") + ". Got: " + r.Algorithm)
+}
+
+//ValidateMultiply validates the matrix multiplication mode that was passed in. Defaults to naive when none is given.
+func (r NewRequest) ValidateMultiply(mode string) (string, *errs.AppError) {
+	if mode == "" {
+		return "naive", nil
+	}
+	if mode != "naive" && mode != "parallel" {
+		return "", errs.NewValidationError("Please provide valid multiply mode: naive, parallel. Got: " + mode)
+	}
+	return mode, nil
+}
+
+//ValidatePriority validates the priority class that was passed in. Defaults to normal when none is given.
+func (r NewRequest) ValidatePriority(priority string) (string, *errs.AppError) {
+	if priority == "" {
+		return "normal", nil
+	}
+	if priority != "interactive" && priority != "normal" && priority != "batch" {
+		return "", errs.NewValidationError("Please provide valid priority: interactive, normal, batch. Got: " + priority)
+	}
+	return priority, nil
+}
+
+//ValidateVerify validates the verification flag that was passed in. Defaults to false when none is given.
+func (r NewRequest) ValidateVerify(verify string) (bool, *errs.AppError) {
+	if verify == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(verify)
+	if err != nil {
+		return false, errs.NewValidationError("Please provide valid verify flag: true, false. Got: " + verify)
+	}
+	return enabled, nil
+}
+
+//ValidateBase validates the base digits are written in. Accepts 2 to 62 and defaults to 10 when none is given.
+func (r NewRequest) ValidateBase(base string) (int, *errs.AppError) {
+	if base == "" {
+		return 10, nil
+	}
+	parsed, err := strconv.Atoi(base)
+	if err != nil || parsed < 2 || parsed > 62 {
+		return 0, errs.NewValidationError("Please provide a valid base. (Numbers from 2 to 62 only) Got: " + base)
+	}
+	return parsed, nil
+}
+
+//ValidateIncludeFib validates whether the number should be included in the response. Defaults to true when none is
+//given.
+func (r NewRequest) ValidateIncludeFib(include string) (bool, *errs.AppError) {
+	if include == "" {
+		return true, nil
+	}
+	included, err := strconv.ParseBool(include)
+	if err != nil {
+		return false, errs.NewValidationError("Please provide valid fib flag: true, false. Got: " + include)
+	}
+	return included, nil
+}
+
+//ValidateFormat validates the encoding of the number in the response. Defaults to fallback when none is given.
+func (r NewRequest) ValidateFormat(format string, fallback string) (string, *errs.AppError) {
+	if format == "" {
+		return fallback, nil
+	}
+	for _, known := range NumberFormats {
+		if format == known {
+			return format, nil
+		}
+	}
+	return "", errs.NewValidationError("Please provide valid format: " + strings.Join(NumberFormats, ", ") + ". Got: " + format)
+}
+
 //ValidateId validates and converts the identifier that was passed in.
 func (r NewRequest) ValidateId(id string) (int64, *errs.AppError) {
 	fibId, err := strconv.Atoi(id)
@@ -0,0 +1,34 @@
+package dto
+
+import "testing"
+
+func TestNewRequest_ValidateRangeSize(t *testing.T) {
+	var request NewRequest
+	// -3..4 sums to 16, or about 1.4 bytes of digits, plus 8 terms of overhead
+	if err := request.ValidateRangeSize(-3, 4, 8*rangeTermOverhead+2); err != nil {
+		t.Error("Range within the byte limit should be accepted. Got:", err)
+	}
+	if err := request.ValidateRangeSize(-3, 4, 8*rangeTermOverhead+1); err == nil {
+		t.Error("Expected an error for a range just over the byte limit")
+	}
+	// With only the byte limit in place, a range of 2^40 terms is rejected without looking at each of them
+	if err := request.ValidateRangeSize(0, 1<<40, 256<<20); err == nil {
+		t.Error("Expected an error for a huge range")
+	}
+}
+
+func TestAbsSum(t *testing.T) {
+	for _, r := range []struct{ from, to int }{{0, 0}, {0, 10}, {5, 9}, {-7, -2}, {-4, 0}, {-3, 6}, {-6, 3}} {
+		want := 0
+		for k := r.from; k <= r.to; k++ {
+			if k < 0 {
+				want -= k
+			} else {
+				want += k
+			}
+		}
+		if got := absSum(r.from, r.to); got != float64(want) {
+			t.Error("Invalid sum of absolute indices. Range:", r.from, r.to, "Want:", want, "Got:", got)
+		}
+	}
+}
@@ -1,12 +1,35 @@
 package dto
 
-import "math/big"
+import "time"
 
 type NewResponse struct {
-	Input    int     `json:"input"`
-	Fib      big.Int `json:"fib"`
-	Duration int64   `json:"duration"`
-	Algo     string  `json:"algo"`
-	Status   string  `json:"status"`
-	Id       int64   `json:"id"`
+	Kind                 string              `json:"kind"`
+	Input                int                 `json:"input"`
+	Index                int64               `json:"index"`
+	Fib                  *Number             `json:"fib,omitempty"`
+	Digits               int                 `json:"digits,omitempty"`
+	SHA256               string              `json:"sha256,omitempty"`
+	Duration             int64               `json:"duration"`
+	Algo                 string              `json:"algo"`
+	Status               string              `json:"status"`
+	Id                   int64               `json:"id"`
+	Multiply             string              `json:"multiply,omitempty"`
+	Priority             string              `json:"priority"`
+	Cached               bool                `json:"cached"`
+	SelectionReason      string              `json:"selection_reason,omitempty"`
+	Verification         string              `json:"verification,omitempty"`
+	VerificationDuration *int64              `json:"verification_duration,omitempty"`
+	Race                 []RaceEntryResponse `json:"race,omitempty"`
+	Lucas                *LucasResponse      `json:"lucas,omitempty"`
+	Recurrence           *RecurrenceResponse `json:"recurrence,omitempty"`
+	Range                *RangeResponse      `json:"range,omitempty"`
+	ResultFile           string              `json:"result_file,omitempty"`
+	ResultBits           int                 `json:"result_bits,omitempty"`
+	FailureReason        string              `json:"failure_reason,omitempty"`
+	CreatedAt            time.Time           `json:"created_at"`
+	StartedAt            *time.Time          `json:"started_at,omitempty"`
+	FinishedAt           *time.Time          `json:"finished_at,omitempty"`
+	QueuePosition        int                 `json:"queue_position,omitempty"`
+	QueueDepth           int                 `json:"queue_depth"`
+	RunningJobs          int                 `json:"running_jobs"`
 }
@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// Formats a Number can be encoded in.
+const (
+	FormatJSONNumber = "json-number"
+	FormatDecimal    = "decimal"
+	FormatHex        = "hex"
+	FormatBase64     = "base64"
+)
+
+// NumberFormats lists the formats clients can choose from.
+var NumberFormats = []string{FormatJSONNumber, FormatDecimal, FormatHex, FormatBase64}
+
+//Number is a big.Int that is encoded in JSON according to its Format. Only json-number produces a bare JSON number;
+//the others produce strings that JavaScript clients can read without losing precision. base64 holds the big-endian
+//bytes of the magnitude, and negative numbers get a leading "-" in every string format.
+type Number struct {
+	Value  *big.Int
+	Format string
+}
+
+func (number Number) MarshalJSON() ([]byte, error) {
+	sign := ""
+	if number.Value.Sign() < 0 {
+		sign = "-"
+	}
+	switch number.Format {
+	case FormatDecimal:
+		return json.Marshal(number.Value.String())
+	case FormatHex:
+		return json.Marshal(number.Value.Text(16))
+	case FormatBase64:
+		return json.Marshal(sign + base64.StdEncoding.EncodeToString(number.Value.Bytes()))
+	default:
+		return number.Value.MarshalJSON()
+	}
+}
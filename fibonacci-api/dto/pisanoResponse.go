@@ -0,0 +1,6 @@
+package dto
+
+type PisanoResponse struct {
+	M      uint64 `json:"m"`
+	Period uint64 `json:"period"`
+}
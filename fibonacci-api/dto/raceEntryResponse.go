@@ -0,0 +1,7 @@
+package dto
+
+type RaceEntryResponse struct {
+	Algo     string `json:"algo"`
+	Duration int64  `json:"duration"`
+	Outcome  string `json:"outcome"`
+}
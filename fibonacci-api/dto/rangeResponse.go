@@ -0,0 +1,15 @@
+package dto
+
+type RangeResponse struct {
+	From   int64       `json:"from"`
+	To     int64       `json:"to"`
+	Total  int64       `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Terms  []RangeTerm `json:"terms,omitempty"`
+}
+
+type RangeTerm struct {
+	Index int64   `json:"index"`
+	Fib   *Number `json:"fib"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+//RecurrenceResponse holds the coefficients and seeds of a linear recurrence job in decimal. The nth term is the
+//response's fib.
+type RecurrenceResponse struct {
+	Coefficients []string `json:"coefficients"`
+	Seeds        []string `json:"seeds"`
+}
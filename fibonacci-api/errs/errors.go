@@ -6,6 +6,8 @@ import "net/http"
 type AppError struct {
 	Code    int
 	Message string
+	// RetryAfter is the number of seconds the client should wait before retrying, or 0 if not applicable.
+	RetryAfter int `json:"-"`
 }
 
 // AsMessage retrieves the message string from current AppError
@@ -23,6 +25,15 @@ func NewValidationError(message string) *AppError {
 	}
 }
 
+// NewBadRequestError defines the parameters for an AppError that occurs when a request is malformed and cannot be read
+// at all.
+func NewBadRequestError(message string) *AppError {
+	return &AppError{
+		Message: message,
+		Code:    http.StatusBadRequest,
+	}
+}
+
 // NewUnexpectedError defines the parameters for an AppError that occurs when an unexpected error occurs.
 func NewUnexpectedError(message string) *AppError {
 	return &AppError{
@@ -30,3 +41,23 @@ func NewUnexpectedError(message string) *AppError {
 		Code:    http.StatusInternalServerError,
 	}
 }
+
+// NewTooManyRequestsError defines the parameters for an AppError that occurs when the server has too much queued work to
+// accept the request. retryAfter is the number of seconds the client should wait.
+func NewTooManyRequestsError(message string, retryAfter int) *AppError {
+	return &AppError{
+		Message:    message,
+		Code:       http.StatusTooManyRequests,
+		RetryAfter: retryAfter,
+	}
+}
+
+// NewUnavailableError defines the parameters for an AppError that occurs when the server cannot accept work at all,
+// for example while shutting down. retryAfter is the number of seconds the client should wait.
+func NewUnavailableError(message string, retryAfter int) *AppError {
+	return &AppError{
+		Message:    message,
+		Code:       http.StatusServiceUnavailable,
+		RetryAfter: retryAfter,
+	}
+}
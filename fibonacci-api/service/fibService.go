@@ -2,33 +2,114 @@
 package service
 
 import (
+	"context"
 	"fibonacci-api/domain"
 	"fibonacci-api/dto"
 	"fibonacci-api/errs"
+	"io"
 	"math/big"
 	"sync"
 )
 
 //FibService processes requests for new and existing sequences.
 type FibService interface {
-	NewSequence(dto.NewRequest, *sync.WaitGroup) (*dto.NewResponse, *errs.AppError)
+	NewSequence(context.Context, dto.NewRequest, *sync.WaitGroup) (*dto.NewResponse, *errs.AppError)
+	NewLucas(context.Context, dto.NewRequest, *sync.WaitGroup) (*dto.NewResponse, *errs.AppError)
+	NewRecurrence(context.Context, dto.NewRequest, *sync.WaitGroup) (*dto.NewResponse, *errs.AppError)
+	NewRange(context.Context, dto.NewRequest, *sync.WaitGroup) (*dto.NewResponse, *errs.AppError)
 	FindById(req dto.NewRequest) (*dto.NewResponse, *errs.AppError)
+	Digits(req dto.NewRequest) (io.ReadSeekCloser, *errs.AppError)
+	RangeTerms(req dto.NewRequest) ([]dto.RangeTerm, *errs.AppError)
+	FibMod(req dto.NewRequest) dto.ModResponse
+	Pisano(req dto.NewRequest) dto.PisanoResponse
+	Algorithms() []dto.AlgorithmResponse
+	Cancel(req dto.NewRequest) (*dto.NewResponse, *errs.AppError)
+	CacheStats() dto.CacheStatsResponse
 }
 
 type DefaultFibService struct {
 	Repo domain.FibRepository
 }
 
-// NewSequence takes in a NewRequest dto and passes the information to the domain in order to process.
-func (service DefaultFibService) NewSequence(req dto.NewRequest, wg *sync.WaitGroup) (*dto.NewResponse, *errs.AppError) {
+// NewSequence takes in a NewRequest dto and passes the information to the domain in order to process. The calculation
+// runs until it finishes or ctx is cancelled.
+func (service DefaultFibService) NewSequence(ctx context.Context, req dto.NewRequest, wg *sync.WaitGroup) (*dto.NewResponse, *errs.AppError) {
 	sequence := domain.Sequence{
 		Fib:      *big.NewInt(-1),
 		Duration: -1,
 		Algo:     req.Algorithm,
 		Input:    req.Input,
-		Status:   "incomplete",
+		Status:   domain.StatusQueued,
+		Multiply: req.Multiply,
+		Priority: domain.Priority(req.Priority),
+		Client:   req.Client,
+		Verify:   req.Verify,
 	}
-	newSequence, err := service.Repo.CalculateFib(sequence, wg)
+	newSequence, err := service.Repo.CalculateFib(ctx, sequence, wg)
+	if err != nil {
+		return nil, err
+	}
+	response := newSequence.ToNewResponseDto()
+	return &response, nil
+}
+
+// NewLucas takes in a NewRequest dto with the parameters P and Q and the index n, and passes them to the domain to
+// compute U_n and V_n as a job. The job is found and cancelled through its id like any other sequence.
+func (service DefaultFibService) NewLucas(ctx context.Context, req dto.NewRequest, wg *sync.WaitGroup) (*dto.NewResponse, *errs.AppError) {
+	sequence := domain.Sequence{
+		Kind:     domain.KindLucas,
+		Fib:      *big.NewInt(-1),
+		Duration: -1,
+		Input:    req.Input,
+		Status:   domain.StatusQueued,
+		Priority: domain.Priority(req.Priority),
+		Client:   req.Client,
+		Lucas:    &domain.LucasParameters{P: req.P, Q: req.Q},
+	}
+	newSequence, err := service.Repo.CalculateFib(ctx, sequence, wg)
+	if err != nil {
+		return nil, err
+	}
+	response := newSequence.ToNewResponseDto()
+	return &response, nil
+}
+
+// NewRecurrence takes in a NewRequest dto with the coefficients and seeds of a linear recurrence and the index n, and
+// passes them to the domain to compute the nth term as a job.
+func (service DefaultFibService) NewRecurrence(ctx context.Context, req dto.NewRequest, wg *sync.WaitGroup) (*dto.NewResponse, *errs.AppError) {
+	sequence := domain.Sequence{
+		Kind:       domain.KindRecurrence,
+		Fib:        *big.NewInt(-1),
+		Duration:   -1,
+		Input:      req.Input,
+		Status:     domain.StatusQueued,
+		Priority:   domain.Priority(req.Priority),
+		Client:     req.Client,
+		Recurrence: &domain.RecurrenceParameters{Coefficients: req.Coefficients, Seeds: req.Seeds},
+	}
+	newSequence, err := service.Repo.CalculateFib(ctx, sequence, wg)
+	if err != nil {
+		return nil, err
+	}
+	response := newSequence.ToNewResponseDto()
+	return &response, nil
+}
+
+// NewRange takes in a NewRequest dto with the algorithm and the first and last index of a range, and passes them to
+// the domain to compute every term of the range in one job.
+func (service DefaultFibService) NewRange(ctx context.Context, req dto.NewRequest, wg *sync.WaitGroup) (*dto.NewResponse, *errs.AppError) {
+	sequence := domain.Sequence{
+		Kind:     domain.KindRange,
+		Duration: -1,
+		Algo:     req.Algorithm,
+		Input:    req.From,
+		Status:   domain.StatusQueued,
+		Multiply: req.Multiply,
+		Priority: domain.Priority(req.Priority),
+		Client:   req.Client,
+		Range:    &domain.RangeParameters{From: int64(req.From), To: int64(req.To)},
+	}
+	newSequence, err := service.Repo.CalculateFib(ctx, sequence, wg)
 	if err != nil {
 		return nil, err
 	}
@@ -44,9 +125,120 @@ func (service DefaultFibService) FindById(req dto.NewRequest) (*dto.NewResponse,
 		return nil, err
 	}
 	response := targetSequence.ToNewResponseDto()
+	if targetSequence.Range != nil {
+		// Ranges are returned a page of terms at a time instead of as one number
+		response.Range.Offset = req.Offset
+		response.Range.Limit = req.Limit
+		response.Range.Terms = targetSequence.Range.Page(req.Offset, req.Limit)
+	}
+	formatNumber(&response, req)
+	queueStatus := service.Repo.QueueStatus(req.Id)
+	response.QueuePosition = queueStatus.Position
+	response.QueueDepth = queueStatus.Depth
+	response.RunningJobs = queueStatus.Running
 	return &response, nil
 }
 
+// Digits takes in a NewRequest and returns a reader over the number of the sequence with the corresponding id written
+// in the requested base. The caller must close it.
+func (service DefaultFibService) Digits(req dto.NewRequest) (io.ReadSeekCloser, *errs.AppError) {
+	return service.Repo.ResultText(req.Id, req.Base)
+}
+
+// FibMod takes in a NewRequest and computes F(n) mod m right away. It needs no job, since it takes O(log n) word
+// operations.
+func (service DefaultFibService) FibMod(req dto.NewRequest) dto.ModResponse {
+	return dto.ModResponse{
+		N:   req.ModIndex.String(),
+		M:   req.Modulus,
+		Fib: domain.FibMod(req.ModIndex, req.Modulus),
+	}
+}
+
+// Pisano takes in a NewRequest and computes the Pisano period of m right away.
+func (service DefaultFibService) Pisano(req dto.NewRequest) dto.PisanoResponse {
+	return dto.PisanoResponse{
+		M:      req.Modulus,
+		Period: domain.PisanoPeriod(req.Modulus),
+	}
+}
+
+// RangeTerms takes in a NewRequest and returns every term of the complete range job with the corresponding id, encoded
+// in the requested format.
+func (service DefaultFibService) RangeTerms(req dto.NewRequest) ([]dto.RangeTerm, *errs.AppError) {
+	targetSequence, err := service.Repo.FindBy(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if targetSequence.Range == nil {
+		return nil, errs.NewValidationError("Sequence is not a range job")
+	}
+	if targetSequence.Status != domain.StatusComplete {
+		return nil, errs.NewValidationError("Sequence is not complete. Status: " + string(targetSequence.Status))
+	}
+	terms := targetSequence.Range.Page(0, 0)
+	if req.Format != "" {
+		for _, term := range terms {
+			term.Fib.Format = req.Format
+		}
+	}
+	return terms, nil
+}
+
+// Cancel takes in a NewRequest and stops the calculation of the sequence with the corresponding id.
+func (service DefaultFibService) Cancel(req dto.NewRequest) (*dto.NewResponse, *errs.AppError) {
+	targetSequence, err := service.Repo.Cancel(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	response := targetSequence.ToNewResponseDto()
+	formatNumber(&response, req)
+	return &response, nil
+}
+
+// formatNumber encodes the numbers in the response in the requested format, or leaves them out if they were not
+// requested.
+func formatNumber(response *dto.NewResponse, req dto.NewRequest) {
+	if req.OmitFib {
+		response.Fib = nil
+		if response.Lucas != nil {
+			response.Lucas.V = nil
+		}
+		if response.Range != nil {
+			response.Range.Terms = nil
+		}
+	}
+	if req.Format == "" {
+		return
+	}
+	if response.Fib != nil {
+		response.Fib.Format = req.Format
+	}
+	if response.Lucas != nil && response.Lucas.V != nil {
+		response.Lucas.V.Format = req.Format
+	}
+	if response.Range != nil {
+		for _, term := range response.Range.Terms {
+			term.Fib.Format = req.Format
+		}
+	}
+}
+
+// Algorithms lists the algorithms registered with the repo.
+func (service DefaultFibService) Algorithms() []dto.AlgorithmResponse {
+	algorithms := service.Repo.Algorithms()
+	response := make([]dto.AlgorithmResponse, len(algorithms))
+	for i, algorithm := range algorithms {
+		response[i] = domain.ToAlgorithmResponseDto(algorithm)
+	}
+	return response
+}
+
+// CacheStats reports the hit and miss counters of the repo's result cache.
+func (service DefaultFibService) CacheStats() dto.CacheStatsResponse {
+	return service.Repo.CacheStats().ToCacheStatsResponseDto()
+}
+
 // NewFibonacciService creates new DefaultFibService using the passed in fibRepo
 func NewFibonacciService(fibRepository domain.FibRepository) DefaultFibService {
 	return DefaultFibService{fibRepository}